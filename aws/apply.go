@@ -0,0 +1,377 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "log"
+  "strings"
+)
+
+func getDrifts(drifts []*Drift, resourceType string) []*Drift {
+  var filtered []*Drift
+  for _, drift := range drifts {
+    if drift.ResourceType == resourceType {
+      filtered = append(filtered, drift)
+    }
+  }
+  return filtered
+}
+
+func hasDriftField(drifts []*Drift, resourceType string, fields ...string) bool {
+  for _, drift := range getDrifts(drifts, resourceType) {
+    for _, field := range fields {
+      if drift.Field == field {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func (c *Client) emitUpdate(resourceType string, resourceID string, state string, message string) {
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         resourceType,
+    ResourceType: resourceType,
+    ResourceID:   resourceID,
+    State:        state,
+    Message:      message,
+  })
+}
+
+func getGroupSubnetIDs(group *autoscalingtypes.AutoScalingGroup) []string {
+  if aws.ToString(group.VPCZoneIdentifier) == "" {
+    return nil
+  }
+  return strings.Split(*group.VPCZoneIdentifier, ",")
+}
+
+func (c *Client) getGroupVPCID(ctx context.Context, group *autoscalingtypes.AutoScalingGroup) (*string, error) {
+  subnetIDs := getGroupSubnetIDs(group)
+  if len(subnetIDs) == 0 {
+    return nil, fmt.Errorf("the auto scaling group %q has no subnets", c.rc.GetGroupName())
+  }
+  res, err := c.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+    SubnetIds: subnetIDs[:1],
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the subnet %s: %w", subnetIDs[0], err)
+  }
+  if len(res.Subnets) != 1 {
+    return nil, fmt.Errorf("received wrong %d != 1 number of subnets with id %s", len(res.Subnets), subnetIDs[0])
+  }
+  return res.Subnets[0].VpcId, nil
+}
+
+func (c *Client) findTargetGroupByName(ctx context.Context, name string) (*elbtypes.TargetGroup, error) {
+  res, err := c.elbClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+    Names: []string{name},
+  })
+  var notFound *elbtypes.TargetGroupNotFoundException
+  if errors.As(err, &notFound) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the target group %q: %w", name, err)
+  }
+  if len(res.TargetGroups) != 1 {
+    return nil, fmt.Errorf("received wrong %d != 1 number of target groups with name %q", len(res.TargetGroups), name)
+  }
+  return &res.TargetGroups[0], nil
+}
+
+func (c *Client) applyTargetGroup(ctx context.Context, stack *Stack, drifts []*Drift) error {
+  if targetGroup := stack.findTargetGroup(c.rc.GetTargetGroupName()); targetGroup != nil {
+    c.targetGroupARN = *targetGroup.TargetGroupArn
+    if !hasDriftField(drifts, resourceTargetGroup, "health check enabled", "health check path", "health check port") {
+      return nil
+    }
+    err := c.withRetries(ctx, "modify the target group", func() error {
+      _, err := c.elbClient.ModifyTargetGroup(ctx, &elasticloadbalancingv2.ModifyTargetGroupInput{
+        TargetGroupArn:     aws.String(c.targetGroupARN),
+        HealthCheckEnabled: aws.Bool(true),
+        HealthCheckPath:    aws.String(c.rc.HealthPath),
+        HealthCheckPort:    aws.String(fmt.Sprintf("%d", c.rc.DaemonPort)),
+      }, withoutELBRetries)
+      return err
+    })
+    if err != nil {
+      return fmt.Errorf("cannot modify the target group %q: %w", c.rc.GetTargetGroupName(), err)
+    }
+    c.emitUpdate(resourceTargetGroup, c.targetGroupARN, "updated", fmt.Sprintf("updated the health check of the target group %q", c.rc.GetTargetGroupName()))
+    return nil
+  }
+  targetGroup, err := c.findTargetGroupByName(ctx, c.rc.GetTargetGroupName())
+  if err != nil {
+    return err
+  }
+  if targetGroup != nil {
+    c.targetGroupARN = *targetGroup.TargetGroupArn
+  } else {
+    vpcID, err := c.getGroupVPCID(ctx, stack.Group)
+    if err != nil {
+      return err
+    }
+    if err := c.CreateTargetGroup(ctx, vpcID); err != nil {
+      return err
+    }
+  }
+  err = c.withRetries(ctx, "attach the target group", func() error {
+    _, err := c.autoscalingClient.AttachLoadBalancerTargetGroups(ctx, &autoscaling.AttachLoadBalancerTargetGroupsInput{
+      AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
+      TargetGroupARNs:      []string{c.targetGroupARN},
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot attach the target group %q to the auto scaling group %q: %w", c.rc.GetTargetGroupName(), c.rc.GetGroupName(), err)
+  }
+  c.emitUpdate(resourceTargetGroup, c.targetGroupARN, "attached", fmt.Sprintf("attached the target group %q to the auto scaling group %q", c.rc.GetTargetGroupName(), c.rc.GetGroupName()))
+  return nil
+}
+
+func (c *Client) applyListener(ctx context.Context, stack *Stack, drifts []*Drift) error {
+  loadBalancer := c.findServingLoadBalancer(stack)
+  if loadBalancer == nil {
+    return c.CreateLoadBalancer(ctx, getGroupSubnetIDs(stack.Group))
+  }
+  loadBalancerName := aws.ToString(loadBalancer.LoadBalancerName)
+  if hasDrift(drifts, resourceLoadBalancer) {
+    if err := c.applyLoadBalancerAttributes(ctx, *loadBalancer.LoadBalancerArn); err != nil {
+      return err
+    }
+  }
+  listener := stack.findListener(*loadBalancer.LoadBalancerArn, c.rc.GetListenerPort())
+  if listener == nil {
+    if err := c.createListener(ctx, *loadBalancer.LoadBalancerArn); err != nil {
+      return err
+    }
+    c.emitUpdate(resourceListener, *loadBalancer.LoadBalancerArn, "created", fmt.Sprintf("created the listener on port %d of the load balancer %q", c.rc.GetListenerPort(), loadBalancerName))
+    return nil
+  }
+  if !hasDrift(drifts, resourceListener) {
+    return nil
+  }
+  err := c.withRetries(ctx, "modify the listener", func() error {
+    _, err := c.elbClient.ModifyListener(ctx, &elasticloadbalancingv2.ModifyListenerInput{
+      ListenerArn:    listener.ListenerArn,
+      DefaultActions: c.makeForwardActions(),
+      Protocol:       elbtypes.ProtocolEnumHttp,
+    }, withoutELBRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot modify the listener on port %d of the load balancer %q: %w", c.rc.GetListenerPort(), loadBalancerName, err)
+  }
+  c.emitUpdate(resourceListener, *listener.ListenerArn, "updated", fmt.Sprintf("updated the listener on port %d of the load balancer %q", c.rc.GetListenerPort(), loadBalancerName))
+  return nil
+}
+
+func (c *Client) applyListenerRule(ctx context.Context, stack *Stack, drifts []*Drift) error {
+  listenerRule := stack.findListenerRule(c.rc.GetListenerPort())
+  if listenerRule == nil {
+    return c.AttachToLoadBalancer(ctx)
+  }
+  if !hasDrift(drifts, resourceListenerRule) {
+    return nil
+  }
+  err := c.withRetries(ctx, "modify the listener rule", func() error {
+    _, err := c.elbClient.ModifyRule(ctx, &elasticloadbalancingv2.ModifyRuleInput{
+      RuleArn:    listenerRule.Rule.RuleArn,
+      Actions:    c.makeForwardActions(),
+      Conditions: c.getListenerRuleConditions(),
+    }, withoutELBRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot modify the listener rule %s: %w", *listenerRule.Rule.RuleArn, err)
+  }
+  c.emitUpdate(resourceListenerRule, *listenerRule.Rule.RuleArn, "updated", fmt.Sprintf("updated the conditions of the listener rule %s", *listenerRule.Rule.RuleArn))
+  return nil
+}
+
+func (c *Client) applyAutoScalingGroup(ctx context.Context) error {
+  err := c.withRetries(ctx, "update the auto scaling group", func() error {
+    _, err := c.autoscalingClient.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+      AutoScalingGroupName:             aws.String(c.rc.GetGroupName()),
+      MaxSize:                          aws.Int32(2 * c.rc.InstancesCount),
+      MinSize:                          aws.Int32(c.rc.InstancesCount),
+      CapacityRebalance:                aws.Bool(true),
+      DesiredCapacity:                  aws.Int32(c.rc.InstancesCount),
+      HealthCheckGracePeriod:           aws.Int32(int32(c.rc.HealthCheckGracePeriod.Seconds())),
+      HealthCheckType:                  aws.String("ELB"),
+      TerminationPolicies:              c.rc.getTerminationPolicies(),
+      NewInstancesProtectedFromScaleIn: aws.Bool(c.rc.ScaleInProtection),
+      MaxInstanceLifetime:              aws.Int32(aws.ToInt32(c.rc.getMaxInstanceLifetime())),
+      DefaultCooldown:                  aws.Int32(c.rc.getDefaultCooldown()),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot update the auto scaling group %q: %w", c.rc.GetGroupName(), err)
+  }
+  c.emitUpdate(resourceAutoScalingGroup, c.rc.GetGroupName(), "updated", fmt.Sprintf("updated the capacity, the health check and the termination settings of the auto scaling group %q", c.rc.GetGroupName()))
+  return nil
+}
+
+func (c *Client) applyInstanceProtection(ctx context.Context, group *autoscalingtypes.AutoScalingGroup) error {
+  var instanceIDs []string
+  for _, instance := range group.Instances {
+    if aws.ToBool(instance.ProtectedFromScaleIn) != c.rc.ScaleInProtection {
+      instanceIDs = append(instanceIDs, *instance.InstanceId)
+    }
+  }
+  for start := 0; start < len(instanceIDs); start += maxInstanceProtectionBatch {
+    end := start + maxInstanceProtectionBatch
+    if end > len(instanceIDs) {
+      end = len(instanceIDs)
+    }
+    err := c.withRetries(ctx, "set the instance protection", func() error {
+      _, err := c.autoscalingClient.SetInstanceProtection(ctx, &autoscaling.SetInstanceProtectionInput{
+        AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
+        InstanceIds:          instanceIDs[start:end],
+        ProtectedFromScaleIn: aws.Bool(c.rc.ScaleInProtection),
+      }, withoutAutoScalingRetries)
+      return err
+    })
+    if err != nil {
+      return fmt.Errorf("cannot set the scale-in protection of the instances of the group %q: %w", c.rc.GetGroupName(), err)
+    }
+  }
+  if len(instanceIDs) != 0 {
+    c.emitUpdate(resourceAutoScalingGroup, c.rc.GetGroupName(), "updated", fmt.Sprintf("set the scale-in protection of %d instances of the auto scaling group %q to %v", len(instanceIDs), c.rc.GetGroupName(), c.rc.ScaleInProtection))
+  }
+  return nil
+}
+
+func (c *Client) applyLifecycleHooks(ctx context.Context, drifts []*Drift) error {
+  for _, drift := range getDrifts(drifts, resourceLifecycleHook) {
+    for _, transition := range []LifecycleTransition{LifecycleTransitionLaunch, LifecycleTransitionTermination} {
+      if drift.Resource != fmt.Sprintf("lifecycle hook %q", c.rc.GetLifecycleHookName(transition)) {
+        continue
+      }
+      var err error
+      if hook := c.rc.getLifecycleHook(transition); hook != nil {
+        err = c.putLifecycleHook(ctx, hook)
+      } else {
+        err = c.deleteLifecycleHook(ctx, transition)
+      }
+      if err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+func (c *Client) applyWarmPool(ctx context.Context) error {
+  if c.rc.HasWarmPool() {
+    return c.PutWarmPool(ctx)
+  }
+  if err := c.deleteWarmPool(ctx, c.rc.GetGroupName()); err != nil {
+    return fmt.Errorf("cannot delete the warm pool of the group %q: %w", c.rc.GetGroupName(), err)
+  }
+  c.emitUpdate(resourceWarmPool, c.rc.GetGroupName(), "deleted", fmt.Sprintf("deleted the warm pool of the group %q", c.rc.GetGroupName()))
+  return nil
+}
+
+func (c *Client) getUnsupportedDrifts(stack *Stack, drifts []*Drift) []*Drift {
+  unsupported := getDrifts(drifts, resourceLaunchTemplate)
+  for _, drift := range getDrifts(drifts, resourceTargetGroup) {
+    if drift.Field == "port" || drift.Field == "protocol" {
+      unsupported = append(unsupported, drift)
+    }
+  }
+  if c.rc.UsesExistingBalancer() {
+    createsListenerRule := stack.findListenerRule(c.rc.GetListenerPort()) == nil
+    for _, drift := range getDrifts(drifts, resourceSharedLoadBalancers) {
+      if createsListenerRule && len(stack.SharedLoadBalancers) == 0 {
+        continue
+      }
+      unsupported = append(unsupported, drift)
+    }
+  }
+  return unsupported
+}
+
+func (c *Client) Apply(ctx context.Context, group *autoscalingtypes.AutoScalingGroup) ([]*Drift, error) {
+  stack, err := c.describeGroupStack(ctx, group)
+  if err != nil {
+    return nil, err
+  }
+  drifts := c.diffStack(stack)
+  if len(drifts) == 0 {
+    return nil, nil
+  }
+  unsupported := c.getUnsupportedDrifts(stack, drifts)
+  if err := c.applyTargetGroup(ctx, stack, drifts); err != nil {
+    return drifts, err
+  }
+  if c.rc.UsesExistingBalancer() {
+    err = c.applyListenerRule(ctx, stack, drifts)
+  } else {
+    err = c.applyListener(ctx, stack, drifts)
+  }
+  if err != nil {
+    return drifts, err
+  }
+  if hasDrift(drifts, resourceAutoScalingGroup) {
+    if err := c.applyAutoScalingGroup(ctx); err != nil {
+      return drifts, err
+    }
+    if hasDriftField(drifts, resourceAutoScalingGroup, "scale-in protection") {
+      if err := c.applyInstanceProtection(ctx, stack.Group); err != nil {
+        return drifts, err
+      }
+    }
+  }
+  if err := c.applyLifecycleHooks(ctx, drifts); err != nil {
+    return drifts, err
+  }
+  if hasDrift(drifts, resourceNotifications) {
+    if err := c.PutNotificationConfiguration(ctx); err != nil {
+      return drifts, err
+    }
+  }
+  if hasDrift(drifts, resourceWarmPool) {
+    if err := c.applyWarmPool(ctx); err != nil {
+      return drifts, err
+    }
+  }
+  if len(unsupported) != 0 {
+    var fields []string
+    for _, drift := range unsupported {
+      fields = append(fields, fmt.Sprintf("%s %s", drift.Resource, drift.Field))
+    }
+    return drifts, fmt.Errorf("cannot apply the drifted fields in place, rebuild the group to change them: %s", strings.Join(fields, "; "))
+  }
+  return drifts, nil
+}
+
+func (b *Builder) Apply(ctx context.Context, spec *RunConfig) ([]*Drift, error) {
+  if err := spec.ValidateArtifactNames(); err != nil {
+    return nil, err
+  }
+  c, err := b.newClient(ctx, spec)
+  if err != nil {
+    return nil, err
+  }
+  defer c.flushWebhook()
+  group, err := c.findAutoScalingGroup(ctx, spec.GetGroupName())
+  if err != nil {
+    return nil, err
+  }
+  if group == nil {
+    log.Printf("the auto scaling group %q does not exist, building it from scratch", spec.GetGroupName())
+    _, err := b.build(ctx, c)
+    return []*Drift{c.makeMissingGroupDrift()}, err
+  }
+  return c.Apply(ctx, group)
+}
@@ -0,0 +1,230 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "log"
+  "strconv"
+  "strings"
+)
+
+const (
+  maxListenerRulePriority    = 50000
+  listenerRuleCreateAttempts = 5
+)
+
+func (c *Client) describeExistingLoadBalancer(ctx context.Context) (*types.LoadBalancer, error) {
+  input := &elasticloadbalancingv2.DescribeLoadBalancersInput{}
+  if strings.HasPrefix(c.rc.ExistingBalancer, "arn:") {
+    input.LoadBalancerArns = []string{c.rc.ExistingBalancer}
+  } else {
+    input.Names = []string{c.rc.ExistingBalancer}
+  }
+  res, err := c.elbClient.DescribeLoadBalancers(ctx, input)
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the load balancer %q: %w", c.rc.ExistingBalancer, err)
+  }
+  if len(res.LoadBalancers) != 1 {
+    return nil, fmt.Errorf("received wrong %d != 1 number of load balancers for %q", len(res.LoadBalancers), c.rc.ExistingBalancer)
+  }
+  return &res.LoadBalancers[0], nil
+}
+
+func (c *Client) findListener(ctx context.Context, loadBalancerARN string, port int32) (*types.Listener, error) {
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeListeners(ctx, &elasticloadbalancingv2.DescribeListenersInput{
+      LoadBalancerArn: aws.String(loadBalancerARN),
+      Marker:          marker,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe listeners of the load balancer %q: %w", c.rc.ExistingBalancer, err)
+    }
+    for i := range res.Listeners {
+      if res.Listeners[i].Port != nil && *res.Listeners[i].Port == port {
+        return &res.Listeners[i], nil
+      }
+    }
+    if res.NextMarker == nil {
+      break
+    }
+    marker = res.NextMarker
+  }
+  return nil, fmt.Errorf("the load balancer %q has no listener on port %d", c.rc.ExistingBalancer, port)
+}
+
+func (c *Client) getFreeRulePriority(ctx context.Context, listenerARN string) (int32, error) {
+  usedPriorities := map[int32]bool{}
+  maxPriority := int32(0)
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeRules(ctx, &elasticloadbalancingv2.DescribeRulesInput{
+      ListenerArn: aws.String(listenerARN),
+      Marker:      marker,
+    })
+    if err != nil {
+      return 0, fmt.Errorf("cannot describe rules of the listener %s: %w", listenerARN, err)
+    }
+    for _, rule := range res.Rules {
+      if rule.IsDefault || rule.Priority == nil {
+        continue
+      }
+      priority, err := strconv.ParseInt(*rule.Priority, 10, 32)
+      if err != nil {
+        return 0, fmt.Errorf("cannot parse the priority %q of the rule %s: %w", *rule.Priority, *rule.RuleArn, err)
+      }
+      usedPriorities[int32(priority)] = true
+      if int32(priority) > maxPriority {
+        maxPriority = int32(priority)
+      }
+    }
+    if res.NextMarker == nil {
+      break
+    }
+    marker = res.NextMarker
+  }
+  if maxPriority < maxListenerRulePriority {
+    return maxPriority + 1, nil
+  }
+  for priority := int32(1); priority <= maxListenerRulePriority; priority++ {
+    if !usedPriorities[priority] {
+      log.Printf("the listener %s uses the top rule priority, taking the free priority %d", listenerARN, priority)
+      return priority, nil
+    }
+  }
+  return 0, fmt.Errorf("the listener %s has no free rule priorities left", listenerARN)
+}
+
+func (c *Client) getListenerRuleConditions() []types.RuleCondition {
+  var conditions []types.RuleCondition
+  if c.rc.HostHeader != "" {
+    conditions = append(conditions, types.RuleCondition{
+      Field:            aws.String("host-header"),
+      HostHeaderConfig: &types.HostHeaderConditionConfig{Values: []string{c.rc.HostHeader}},
+    })
+  }
+  if c.rc.PathPattern != "" {
+    conditions = append(conditions, types.RuleCondition{
+      Field:             aws.String("path-pattern"),
+      PathPatternConfig: &types.PathPatternConditionConfig{Values: []string{c.rc.PathPattern}},
+    })
+  }
+  return conditions
+}
+
+func (c *Client) isOwnListenerRule(rule *types.Rule) bool {
+  targetGroupFound := false
+  for _, targetGroupARN := range getForwardTargetGroupARNs(rule.Actions) {
+    targetGroupFound = targetGroupFound || targetGroupARN == c.targetGroupARN
+  }
+  if !targetGroupFound {
+    return false
+  }
+  var desiredHosts, desiredPaths []string
+  if c.rc.HostHeader != "" {
+    desiredHosts = append(desiredHosts, c.rc.HostHeader)
+  }
+  if c.rc.PathPattern != "" {
+    desiredPaths = append(desiredPaths, c.rc.PathPattern)
+  }
+  return joinOrNone(getRuleConditionValues(rule, "host-header")) == joinOrNone(desiredHosts) && joinOrNone(getRuleConditionValues(rule, "path-pattern")) == joinOrNone(desiredPaths)
+}
+
+func (c *Client) findOwnListenerRule(ctx context.Context, listenerARN string) (*types.Rule, error) {
+  rules, err := c.describeRules(ctx, listenerARN)
+  if err != nil {
+    return nil, err
+  }
+  for i := range rules {
+    if !rules[i].IsDefault && c.isOwnListenerRule(&rules[i]) {
+      return &rules[i], nil
+    }
+  }
+  return nil, nil
+}
+
+func (c *Client) createListenerRule(ctx context.Context, listenerARN string) (*types.Rule, error) {
+  attempted := false
+  for attempt := 0; attempt < listenerRuleCreateAttempts; attempt++ {
+    priority, err := c.getFreeRulePriority(ctx, listenerARN)
+    if err != nil {
+      return nil, err
+    }
+    var rule *types.Rule
+    err = c.withRetries(ctx, "create a listener rule", func() error {
+      if attempted {
+        ownRule, err := c.findOwnListenerRule(ctx, listenerARN)
+        if err != nil {
+          return err
+        }
+        if ownRule != nil {
+          log.Printf("the listener rule %s has been created by the previous attempt", aws.ToString(ownRule.RuleArn))
+          rule = ownRule
+          return nil
+        }
+      }
+      attempted = true
+      res, err := c.elbClient.CreateRule(ctx, &elasticloadbalancingv2.CreateRuleInput{
+        Actions:     c.makeForwardActions(),
+        Conditions:  c.getListenerRuleConditions(),
+        ListenerArn: aws.String(listenerARN),
+        Priority:    aws.Int32(priority),
+      }, withoutELBRetries)
+      if err != nil {
+        return err
+      }
+      if len(res.Rules) != 1 {
+        return fmt.Errorf("created wrong %d != 1 number of listener rules on the load balancer %q", len(res.Rules), c.rc.ExistingBalancer)
+      }
+      rule = &res.Rules[0]
+      return nil
+    })
+    var priorityInUse *types.PriorityInUseException
+    if errors.As(err, &priorityInUse) {
+      log.Printf("rule priority %d of the load balancer %q has just been taken, retrying", priority, c.rc.ExistingBalancer)
+      continue
+    }
+    if err != nil {
+      return nil, fmt.Errorf("cannot create a listener rule on the load balancer %q: %w", c.rc.ExistingBalancer, err)
+    }
+    return rule, nil
+  }
+  return nil, fmt.Errorf("cannot allocate a listener rule priority on the load balancer %q in %d attempts", c.rc.ExistingBalancer, listenerRuleCreateAttempts)
+}
+
+func (c *Client) AttachToLoadBalancer(ctx context.Context) error {
+  loadBalancer, err := c.describeExistingLoadBalancer(ctx)
+  if err != nil {
+    return err
+  }
+  if loadBalancer.State == nil || loadBalancer.State.Code != types.LoadBalancerStateEnumActive {
+    return fmt.Errorf("the load balancer %q is not in an active state", c.rc.ExistingBalancer)
+  }
+  listener, err := c.findListener(ctx, *loadBalancer.LoadBalancerArn, c.rc.GetListenerPort())
+  if err != nil {
+    return err
+  }
+  rule, err := c.createListenerRule(ctx, *listener.ListenerArn)
+  if err != nil {
+    return err
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepLoadBalancer,
+    ResourceType: "listener rule",
+    ResourceID:   *rule.RuleArn,
+    State:        "created",
+    Message:      fmt.Sprintf("created listener rule with priority %s on the load balancer %q (%s)", aws.ToString(rule.Priority), *loadBalancer.LoadBalancerName, *rule.RuleArn),
+  })
+  c.listenerRuleARN = *rule.RuleArn
+  c.loadBalancerName = *loadBalancer.LoadBalancerName
+  c.loadBalancerDNSName = *loadBalancer.DNSName
+  c.loadBalancerHostedZoneID = *loadBalancer.CanonicalHostedZoneId
+  c.loadBalancerIPAddressType = loadBalancer.IpAddressType
+  c.listenerProtocol = listener.Protocol
+  return nil
+}
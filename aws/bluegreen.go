@@ -0,0 +1,51 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "time"
+)
+
+type BlueGreenOptions struct {
+  Group        string
+  BakeTime     time.Duration
+  MaxErrorRate float64
+}
+
+func (c *Client) RunBlueGreen(ctx context.Context, stable *Client, options *BlueGreenOptions) error {
+  stack, route, instanceData, err := c.prepareRelease(ctx, stable)
+  if err != nil {
+    return err
+  }
+  log.Printf("will create a green auto scaling group %q next to %q and switch all the traffic to it once it is healthy", c.rc.GetGroupName(), stable.rc.GetGroupName())
+  if err := c.buildNextStack(ctx, instanceData, getGroupSubnetIDs(stack.Group), route); err != nil {
+    return c.rollBackRelease(route, err)
+  }
+  if err := c.forwardAllTraffic(ctx, route, c.targetGroupARN); err != nil {
+    return c.rollBackRelease(route, err)
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepTrafficRoute,
+    ResourceType: "target group",
+    ResourceID:   c.targetGroupARN,
+    State:        "100%",
+    Message:      fmt.Sprintf("switched all the traffic from %q to %q, keeping %q for %v", stable.rc.GetGroupName(), c.rc.GetGroupName(), stable.rc.GetGroupName(), options.BakeTime),
+  })
+  if err := c.observeNext(ctx, route, options.BakeTime, options.MaxErrorRate, "the bake time"); err != nil {
+    return c.rollBackRelease(route, err)
+  }
+  return c.retireStableGroup(stable, stack, route)
+}
+
+func (b *Builder) BlueGreen(ctx context.Context, spec *RunConfig, options BlueGreenOptions) error {
+  stable, c, err := b.newReleaseClients(ctx, spec, options.Group)
+  if err != nil {
+    return err
+  }
+  defer stable.flushWebhook()
+  defer c.flushWebhook()
+  _, err = c.finishBuild(ctx, c.RunBlueGreen(ctx, stable, &options))
+  return err
+}
@@ -0,0 +1,82 @@
+package aws
+
+import (
+  "context"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const (
+  stepAMI              = "ami"
+  stepTargetGroup      = "target group"
+  stepLoadBalancer     = "load balancer"
+  stepLaunchTemplate   = "launch template"
+  stepDNSRecord        = "dns record"
+  stepAutoScalingGroup = "auto scaling group"
+  stepWarmPool         = "warm pool"
+)
+
+func (c *Client) makeBuildSteps(instanceData *ec2types.Instance, subnetIDs []string, routingSteps ...*buildStep) []*buildStep {
+  steps := []*buildStep{
+    {
+      name: stepAMI,
+      run:  c.CreateAMI,
+    },
+    {
+      name: stepTargetGroup,
+      run: func(ctx context.Context) error {
+        return c.CreateTargetGroup(ctx, instanceData.VpcId)
+      },
+    },
+  }
+  groupDependencies := []string{stepLaunchTemplate}
+  for _, step := range routingSteps {
+    steps = append(steps, step)
+    groupDependencies = append(groupDependencies, step.name)
+  }
+  steps = append(steps, &buildStep{
+    name:      stepLaunchTemplate,
+    dependsOn: []string{stepAMI},
+    run: func(ctx context.Context) error {
+      return c.CreateLaunchTemplate(ctx, instanceData)
+    },
+  }, &buildStep{
+    name:      stepAutoScalingGroup,
+    dependsOn: groupDependencies,
+    run: func(ctx context.Context) error {
+      return c.CreateAutoScalingGroup(ctx, subnetIDs)
+    },
+  })
+  if c.rc.HasWarmPool() {
+    steps = append(steps, &buildStep{
+      name:      stepWarmPool,
+      dependsOn: []string{stepAutoScalingGroup},
+      run:       c.PutWarmPool,
+    })
+  }
+  return steps
+}
+
+func (c *Client) Build(ctx context.Context, instanceData *ec2types.Instance, subnetIDs []string) error {
+  loadBalancerStep := func(ctx context.Context) error {
+    return c.CreateLoadBalancer(ctx, subnetIDs)
+  }
+  if c.rc.UsesExistingBalancer() {
+    loadBalancerStep = c.AttachToLoadBalancer
+  }
+  routingSteps := []*buildStep{
+    {
+      name:      stepLoadBalancer,
+      dependsOn: []string{stepTargetGroup},
+      run:       loadBalancerStep,
+    },
+  }
+  if c.rc.HasDNSRecord() {
+    routingSteps = append(routingSteps, &buildStep{
+      name:      stepDNSRecord,
+      dependsOn: []string{stepLoadBalancer},
+      run:       c.CreateDNSRecord,
+    })
+  }
+  c.buildSteps = c.makeBuildSteps(instanceData, subnetIDs, routingSteps...)
+  return runBuildSteps(ctx, c.buildSteps, c.emit)
+}
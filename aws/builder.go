@@ -0,0 +1,228 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "log"
+  "strings"
+)
+
+type Result struct {
+  AMIID                string
+  LaunchTemplateID     string
+  TargetGroupARN       string
+  LoadBalancerARN      string
+  LoadBalancerName     string
+  LoadBalancerDNSName  string
+  ListenerRuleARN      string
+  DNSName              string
+  AutoScalingGroupName string
+  HealthURL            string
+  Report               *Report
+}
+
+type Builder struct {
+  awsConfig        *aws.Config
+  sinks            []EventSink
+  preflightChecks  bool
+  cleanupOnFailure bool
+}
+
+type Option func(b *Builder)
+
+func WithAWSConfig(awsConfig aws.Config) Option {
+  return func(b *Builder) {
+    b.awsConfig = &awsConfig
+  }
+}
+
+func WithEventSink(sink EventSink) Option {
+  return func(b *Builder) {
+    b.sinks = append(b.sinks, sink)
+  }
+}
+
+func WithoutPreflightChecks() Option {
+  return func(b *Builder) {
+    b.preflightChecks = false
+  }
+}
+
+func WithoutCleanup() Option {
+  return func(b *Builder) {
+    b.cleanupOnFailure = false
+  }
+}
+
+func NewBuilder(options ...Option) *Builder {
+  b := &Builder{
+    preflightChecks:  true,
+    cleanupOnFailure: true,
+  }
+  for _, option := range options {
+    option(b)
+  }
+  return b
+}
+
+func (b *Builder) getAWSConfig(ctx context.Context) (aws.Config, error) {
+  if b.awsConfig != nil {
+    return *b.awsConfig, nil
+  }
+  return LoadAWSConfig(ctx)
+}
+
+func (b *Builder) newClient(ctx context.Context, spec *RunConfig) (*Client, error) {
+  awsConfig, err := b.getAWSConfig(ctx)
+  if err != nil {
+    return nil, err
+  }
+  c := NewClient(awsConfig, spec)
+  for _, sink := range b.sinks {
+    c.AddEventSink(sink)
+  }
+  if spec.NotifyURL != "" {
+    c.webhook = NewWebhookSink(spec.NotifyURL, spec.GetGroupName())
+    c.webhook.start()
+    c.AddEventSink(c.webhook)
+  }
+  return c, nil
+}
+
+func (c *Client) logBuildPlan(instanceData *ec2types.Instance, defaultVPCID string, subnetIDs []string) {
+  log.Printf("will create an AMI %q from the instance %s", c.rc.GetAMIName(), c.rc.InstanceID)
+  log.Printf("will create a launch template %q", c.rc.GetLaunchTemplateName())
+  log.Printf("will create a target group %q in VPC %s", c.rc.GetTargetGroupName(), defaultVPCID)
+  if c.rc.UsesExistingBalancer() {
+    log.Printf("will add a listener rule to the existing load balancer %q on port %d", c.rc.ExistingBalancer, c.rc.GetListenerPort())
+  } else {
+    log.Printf("will create a load balancer %q in subnets %s", c.rc.GetBalancerName(), strings.Join(subnetIDs, ", "))
+  }
+  if c.rc.HasDNSRecord() {
+    log.Printf("will point the DNS name %q in the hosted zone %s at the load balancer", c.rc.DNSName, c.rc.HostedZoneID)
+  }
+  log.Printf("will create an auto scaling group %q with %d %s spot instances", c.rc.GetGroupName(), c.rc.InstancesCount, instanceData.InstanceType)
+}
+
+func (c *Client) makeResult(buildErr error) *Result {
+  result := &Result{
+    AMIID:               c.amiID,
+    LaunchTemplateID:    c.launchTemplateID,
+    TargetGroupARN:      c.targetGroupARN,
+    LoadBalancerARN:     c.loadBalancerARN,
+    LoadBalancerName:    c.loadBalancerName,
+    LoadBalancerDNSName: c.loadBalancerDNSName,
+    ListenerRuleARN:     c.listenerRuleARN,
+    Report:              c.MakeReport(buildErr),
+  }
+  if len(c.dnsRecordTypes) != 0 {
+    result.DNSName = c.rc.DNSName
+  }
+  if c.autoScalingGroupCreationStarted {
+    result.AutoScalingGroupName = c.rc.GetGroupName()
+  }
+  if c.loadBalancerDNSName != "" {
+    result.HealthURL = c.getHealthURL()
+  }
+  return result
+}
+
+func (c *Client) cleanupDetached() {
+  ctx, cancel := c.newCleanupContext()
+  defer cancel()
+  c.Cleanup(ctx)
+}
+
+func (c *Client) flushWebhook() {
+  if c.webhook != nil {
+    c.webhook.flush()
+  }
+}
+
+func (c *Client) sendReport(ctx context.Context, report *Report) {
+  if ctx.Err() != nil {
+    var cancel context.CancelFunc
+    ctx, cancel = context.WithTimeout(context.Background(), webhookTimeout)
+    defer cancel()
+  }
+  if err := c.webhook.SendReport(ctx, report); err != nil {
+    log.Println(err)
+  }
+}
+
+func (c *Client) finishBuild(ctx context.Context, buildErr error) (*Result, error) {
+  if err := c.WriteReport(buildErr); err != nil {
+    log.Println(err)
+  }
+  result := c.makeResult(buildErr)
+  if c.webhook != nil {
+    c.sendReport(ctx, result.Report)
+  }
+  return result, buildErr
+}
+
+func (b *Builder) Build(ctx context.Context, spec *RunConfig) (*Result, error) {
+  if err := spec.ValidateArtifactNames(); err != nil {
+    return nil, err
+  }
+  c, err := b.newClient(ctx, spec)
+  if err != nil {
+    return nil, err
+  }
+  return b.build(ctx, c)
+}
+
+func (b *Builder) build(ctx context.Context, c *Client) (*Result, error) {
+  instanceData, err := c.DescribeInstance(ctx)
+  if err != nil {
+    return c.finishBuild(ctx, err)
+  }
+  defaultVPCID, err := c.GetDefaultVPCID(ctx)
+  if err != nil {
+    return c.finishBuild(ctx, err)
+  }
+  subnetIDs, err := c.GetSubnets(ctx, defaultVPCID)
+  if err != nil {
+    return c.finishBuild(ctx, err)
+  }
+  if b.preflightChecks {
+    if err := c.RunPreflightChecks(ctx, instanceData, subnetIDs); err != nil {
+      return c.finishBuild(ctx, err)
+    }
+  }
+  c.logBuildPlan(instanceData, defaultVPCID, subnetIDs)
+  if err := c.Build(ctx, instanceData, subnetIDs); err != nil {
+    if b.cleanupOnFailure {
+      c.cleanupDetached()
+    }
+    return c.finishBuild(ctx, err)
+  }
+  if c.rc.SmokeTest {
+    if err := c.RunSmokeTest(ctx); err != nil {
+      if c.rc.SmokeCleanup && b.cleanupOnFailure {
+        c.cleanupDetached()
+      } else {
+        c.ReportCreatedArtifacts()
+      }
+      return c.finishBuild(ctx, err)
+    }
+  }
+  c.ReportCreatedArtifacts()
+  return c.finishBuild(ctx, nil)
+}
+
+func (b *Builder) EmitTemplate(ctx context.Context, spec *RunConfig) (string, error) {
+  if spec.Emit != EmitCloudFormation {
+    return "", fmt.Errorf("unknown template kind %q, expected %q", spec.Emit, EmitCloudFormation)
+  }
+  if err := spec.ValidateArtifactNames(); err != nil {
+    return "", err
+  }
+  c, err := b.newClient(ctx, spec)
+  if err != nil {
+    return "", err
+  }
+  return c.MakeCloudFormationTemplate(ctx, spec.EmitFormat)
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "strconv"
+  "strings"
+  "time"
+)
+
+type CanaryOptions struct {
+  Group        string
+  Steps        []int32
+  StepDuration time.Duration
+  MaxErrorRate float64
+}
+
+func DefaultCanarySteps() []int32 {
+  return []int32{5, 25, 100}
+}
+
+func ParseCanarySteps(value string) ([]int32, error) {
+  var steps []int32
+  for _, part := range strings.Split(value, ",") {
+    percent, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+    if err != nil {
+      return nil, fmt.Errorf("cannot parse the canary step %q: %w", part, err)
+    }
+    if percent < 1 || percent > 100 {
+      return nil, fmt.Errorf("the canary step %d%% is out of the 1-100%% range", percent)
+    }
+    if len(steps) != 0 && int32(percent) <= steps[len(steps)-1] {
+      return nil, fmt.Errorf("the canary steps must increase, got %d%% after %d%%", percent, steps[len(steps)-1])
+    }
+    steps = append(steps, int32(percent))
+  }
+  if steps[len(steps)-1] != 100 {
+    return nil, fmt.Errorf("the last canary step must be 100%%, got %d%%", steps[len(steps)-1])
+  }
+  return steps, nil
+}
+
+func (c *Client) RunCanary(ctx context.Context, stable *Client, options *CanaryOptions) error {
+  stack, route, instanceData, err := c.prepareRelease(ctx, stable)
+  if err != nil {
+    return err
+  }
+  log.Printf("will create a canary auto scaling group %q next to %q and shift the traffic in steps %v", c.rc.GetGroupName(), stable.rc.GetGroupName(), options.Steps)
+  if err := c.buildNextStack(ctx, instanceData, getGroupSubnetIDs(stack.Group), route); err != nil {
+    return c.rollBackRelease(route, err)
+  }
+  for _, percent := range options.Steps {
+    if err := c.setNextWeight(ctx, route, percent); err != nil {
+      return c.rollBackRelease(route, err)
+    }
+    if err := c.observeNext(ctx, route, options.StepDuration, options.MaxErrorRate, fmt.Sprintf("%d%% of the traffic", percent)); err != nil {
+      return c.rollBackRelease(route, err)
+    }
+  }
+  if err := c.forwardAllTraffic(ctx, route, c.targetGroupARN); err != nil {
+    return c.rollBackRelease(route, err)
+  }
+  return c.retireStableGroup(stable, stack, route)
+}
+
+func (b *Builder) Canary(ctx context.Context, spec *RunConfig, options CanaryOptions) error {
+  if len(options.Steps) == 0 {
+    options.Steps = DefaultCanarySteps()
+  }
+  stable, c, err := b.newReleaseClients(ctx, spec, options.Group)
+  if err != nil {
+    return err
+  }
+  defer stable.flushWebhook()
+  defer c.flushWebhook()
+  _, err = c.finishBuild(ctx, c.RunCanary(ctx, stable, &options))
+  return err
+}
@@ -0,0 +1,41 @@
+package aws
+
+import (
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestParseCanarySteps(t *testing.T) {
+  for _, test := range []struct {
+    value   string
+    want    []int32
+    wantErr string
+  }{
+    {value: "100", want: []int32{100}},
+    {value: "10,50,100", want: []int32{10, 50, 100}},
+    {value: " 5, 25 ,100", want: []int32{5, 25, 100}},
+    {value: "10,fifty,100", wantErr: `cannot parse the canary step "fifty"`},
+    {value: "0,100", wantErr: "the canary step 0% is out of the 1-100% range"},
+    {value: "10,150", wantErr: "the canary step 150% is out of the 1-100% range"},
+    {value: "50,10,100", wantErr: "the canary steps must increase, got 10% after 50%"},
+    {value: "10,10,100", wantErr: "the canary steps must increase, got 10% after 10%"},
+    {value: "10,50", wantErr: "the last canary step must be 100%, got 50%"},
+    {value: "", wantErr: `cannot parse the canary step ""`},
+  } {
+    steps, err := ParseCanarySteps(test.value)
+    if test.wantErr != "" {
+      if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+        t.Errorf("%q: expected the error %q, got %v", test.value, test.wantErr, err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%q: unexpected error %v", test.value, err)
+      continue
+    }
+    if !reflect.DeepEqual(steps, test.want) {
+      t.Errorf("%q: expected the steps %v, got %v", test.value, test.want, steps)
+    }
+  }
+}
@@ -0,0 +1,310 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "github.com/aws/aws-sdk-go-v2/service/route53"
+  route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+  "log"
+  "strings"
+  "time"
+)
+
+type CleanupStatus string
+
+const cleanupTimeoutMargin = 5 * time.Minute
+
+const (
+  CleanupStatusDeleted CleanupStatus = "deleted"
+  CleanupStatusFailed  CleanupStatus = "failed"
+  CleanupStatusSkipped CleanupStatus = "skipped"
+)
+
+type CleanupResult struct {
+  ResourceType string        `json:"resource_type"`
+  ID           string        `json:"id"`
+  Status       CleanupStatus `json:"status"`
+  Message      string        `json:"message,omitempty"`
+}
+
+type CleanupReport struct {
+  Results []*CleanupResult `json:"results"`
+}
+
+func (c *Client) emitCleanup(resourceType string, id string, status CleanupStatus, message string) {
+  c.emit(&Event{
+    Kind:         EventKindCleanup,
+    Step:         "cleanup",
+    ResourceType: resourceType,
+    ResourceID:   id,
+    State:        string(status),
+    Message:      message,
+  })
+}
+
+func (c *Client) recordCleanup(report *CleanupReport, resourceType string, id string, err error) bool {
+  if err != nil {
+    c.emitCleanup(resourceType, id, CleanupStatusFailed, fmt.Sprintf("cannot delete %s %q: %v", resourceType, id, err))
+    report.Results = append(report.Results, &CleanupResult{
+      ResourceType: resourceType,
+      ID:           id,
+      Status:       CleanupStatusFailed,
+      Message:      err.Error(),
+    })
+    return false
+  }
+  c.emitCleanup(resourceType, id, CleanupStatusDeleted, fmt.Sprintf("deleted %s %q", resourceType, id))
+  report.Results = append(report.Results, &CleanupResult{
+    ResourceType: resourceType,
+    ID:           id,
+    Status:       CleanupStatusDeleted,
+  })
+  return true
+}
+
+func (c *Client) skipCleanup(report *CleanupReport, resourceType string, id string, reason string) {
+  c.emitCleanup(resourceType, id, CleanupStatusSkipped, fmt.Sprintf("skipped deleting %s %q: %s", resourceType, id, reason))
+  report.Results = append(report.Results, &CleanupResult{
+    ResourceType: resourceType,
+    ID:           id,
+    Status:       CleanupStatusSkipped,
+    Message:      reason,
+  })
+}
+
+func (r *CleanupReport) Count(status CleanupStatus) int {
+  count := 0
+  for _, result := range r.Results {
+    if result.Status == status {
+      count++
+    }
+  }
+  return count
+}
+
+func (r *CleanupReport) Succeeded() bool {
+  return r.Count(CleanupStatusFailed) == 0 && r.Count(CleanupStatusSkipped) == 0
+}
+
+func (r *CleanupReport) String() string {
+  return fmt.Sprintf("%d deleted, %d failed, %d skipped", r.Count(CleanupStatusDeleted), r.Count(CleanupStatusFailed), r.Count(CleanupStatusSkipped))
+}
+
+func (c *Client) waitAutoScalingGroupDeleted(ctx context.Context) error {
+  finishTime := time.Now().Add(c.rc.UpdateTimeout)
+  for time.Now().Before(finishTime) {
+    res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+      AutoScalingGroupNames: []string{c.rc.GetGroupName()},
+    })
+    if err != nil {
+      log.Printf("cannot get description of the autoscaling group %q: %v", c.rc.GetGroupName(), err)
+    } else if len(res.AutoScalingGroups) == 0 {
+      return nil
+    } else {
+      log.Printf("group %q: deleting, %d instances left", c.rc.GetGroupName(), len(res.AutoScalingGroups[0].Instances))
+    }
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
+  }
+  return fmt.Errorf("the autoscaling group %q has not been deleted within the timeout %v", c.rc.GetGroupName(), c.rc.UpdateTimeout)
+}
+
+func (c *Client) cleanupAutoScalingGroup(ctx context.Context, report *CleanupReport) bool {
+  if !c.autoScalingGroupCreationStarted {
+    return true
+  }
+  res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+    AutoScalingGroupNames: []string{c.rc.GetGroupName()},
+  })
+  if err == nil && len(res.AutoScalingGroups) == 0 {
+    return true
+  }
+  if err == nil && res.AutoScalingGroups[0].WarmPoolConfiguration != nil {
+    c.recordCleanup(report, "warm pool", c.rc.GetGroupName(), c.deleteWarmPool(ctx, c.rc.GetGroupName()))
+  }
+  _, err = c.autoscalingClient.DeleteAutoScalingGroup(ctx, &autoscaling.DeleteAutoScalingGroupInput{
+    AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
+    ForceDelete:          aws.Bool(true),
+  })
+  if err == nil {
+    err = c.waitAutoScalingGroupDeleted(ctx)
+  }
+  return c.recordCleanup(report, "auto scaling group", c.rc.GetGroupName(), err)
+}
+
+func (c *Client) cleanupDNSRecord(ctx context.Context, report *CleanupReport) {
+  if len(c.dnsRecordTypes) == 0 {
+    return
+  }
+  var changes []route53types.Change
+  for _, recordType := range c.dnsRecordTypes {
+    if previous := c.findPreviousDNSRecordSet(recordType); previous != nil {
+      log.Printf("restoring the previous %s record %q", recordType, c.rc.DNSName)
+      changes = append(changes, route53types.Change{
+        Action:            route53types.ChangeActionUpsert,
+        ResourceRecordSet: previous,
+      })
+      continue
+    }
+    changes = append(changes, c.makeDNSRecordChanges(route53types.ChangeActionDelete, []route53types.RRType{recordType})...)
+  }
+  _, err := c.route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+    ChangeBatch: &route53types.ChangeBatch{
+      Changes: changes,
+    },
+    HostedZoneId: aws.String(c.rc.HostedZoneID),
+  })
+  c.recordCleanup(report, "DNS record", c.rc.DNSName, err)
+}
+
+func (c *Client) cleanupListenerRule(ctx context.Context, report *CleanupReport) bool {
+  if c.listenerRuleARN == "" {
+    return true
+  }
+  _, err := c.elbClient.DeleteRule(ctx, &elasticloadbalancingv2.DeleteRuleInput{
+    RuleArn: aws.String(c.listenerRuleARN),
+  })
+  return c.recordCleanup(report, "listener rule", c.listenerRuleARN, err)
+}
+
+func (c *Client) waitLoadBalancerDeleted(ctx context.Context) error {
+  finishTime := time.Now().Add(c.rc.UpdateTimeout)
+  for time.Now().Before(finishTime) {
+    res, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+      LoadBalancerArns: []string{c.loadBalancerARN},
+    })
+    var notFound *elbtypes.LoadBalancerNotFoundException
+    if errors.As(err, &notFound) || (err == nil && len(res.LoadBalancers) == 0) {
+      return nil
+    }
+    if err != nil {
+      log.Printf("cannot get description of the balancer %q: %v", c.loadBalancerName, err)
+    } else {
+      log.Printf("load balancer %q: deleting", c.loadBalancerName)
+    }
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
+  }
+  return fmt.Errorf("the balancer %q has not been deleted within the timeout %v", c.loadBalancerName, c.rc.UpdateTimeout)
+}
+
+func (c *Client) cleanupLoadBalancer(ctx context.Context, report *CleanupReport) bool {
+  if c.loadBalancerARN == "" {
+    return true
+  }
+  if err := c.liftDeletionProtection(ctx, c.loadBalancerARN); err != nil {
+    return c.recordCleanup(report, "load balancer", c.loadBalancerName, err)
+  }
+  _, err := c.elbClient.DeleteLoadBalancer(ctx, &elasticloadbalancingv2.DeleteLoadBalancerInput{
+    LoadBalancerArn: aws.String(c.loadBalancerARN),
+  })
+  if err == nil {
+    err = c.waitLoadBalancerDeleted(ctx)
+  }
+  return c.recordCleanup(report, "load balancer", c.loadBalancerName, err)
+}
+
+func (c *Client) cleanupTargetGroup(ctx context.Context, report *CleanupReport) {
+  if c.targetGroupARN == "" {
+    return
+  }
+  _, err := c.elbClient.DeleteTargetGroup(ctx, &elasticloadbalancingv2.DeleteTargetGroupInput{
+    TargetGroupArn: aws.String(c.targetGroupARN),
+  })
+  c.recordCleanup(report, "target group", c.targetGroupARN, err)
+}
+
+func (c *Client) cleanupLaunchTemplate(ctx context.Context, report *CleanupReport) {
+  if c.launchTemplateID == "" {
+    return
+  }
+  _, err := c.ec2Client.DeleteLaunchTemplate(ctx, &ec2.DeleteLaunchTemplateInput{
+    LaunchTemplateId: aws.String(c.launchTemplateID),
+  })
+  c.recordCleanup(report, "launch template", c.launchTemplateID, err)
+}
+
+func (c *Client) getImageSnapshotIDs(ctx context.Context, imageID string) ([]string, error) {
+  res, err := c.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+    ImageIds: []string{imageID},
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot get image description for AMI %s: %w", imageID, err)
+  }
+  var snapshotIDs []string
+  for _, image := range res.Images {
+    for _, mapping := range image.BlockDeviceMappings {
+      if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+        snapshotIDs = append(snapshotIDs, *mapping.Ebs.SnapshotId)
+      }
+    }
+  }
+  return snapshotIDs, nil
+}
+
+func (c *Client) cleanupAMI(ctx context.Context, report *CleanupReport) {
+  if c.amiID == "" {
+    return
+  }
+  snapshotIDs, snapshotsErr := c.getImageSnapshotIDs(ctx, c.amiID)
+  _, err := c.ec2Client.DeregisterImage(ctx, &ec2.DeregisterImageInput{
+    ImageId: aws.String(c.amiID),
+  })
+  if !c.recordCleanup(report, "AMI", c.amiID, err) {
+    return
+  }
+  if snapshotsErr != nil {
+    c.skipCleanup(report, "snapshots of AMI", c.amiID, snapshotsErr.Error())
+    return
+  }
+  for _, snapshotID := range snapshotIDs {
+    _, err := c.ec2Client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+      SnapshotId: aws.String(snapshotID),
+    })
+    c.recordCleanup(report, "snapshot", snapshotID, err)
+  }
+}
+
+func (c *Client) newCleanupContext() (context.Context, context.CancelFunc) {
+  return context.WithTimeout(context.Background(), 2*c.rc.UpdateTimeout+cleanupTimeoutMargin)
+}
+
+func (c *Client) Cleanup(ctx context.Context) *CleanupReport {
+  report := &CleanupReport{}
+  groupDeleted := c.cleanupAutoScalingGroup(ctx, report)
+  c.cleanupDNSRecord(ctx, report)
+  ruleDeleted := c.cleanupListenerRule(ctx, report)
+  balancerDeleted := c.cleanupLoadBalancer(ctx, report)
+  var targetGroupBlockers []string
+  if !groupDeleted {
+    targetGroupBlockers = append(targetGroupBlockers, "the auto scaling group")
+  }
+  if !ruleDeleted {
+    targetGroupBlockers = append(targetGroupBlockers, "the listener rule")
+  }
+  if !balancerDeleted {
+    targetGroupBlockers = append(targetGroupBlockers, "the load balancer")
+  }
+  if c.targetGroupARN != "" && len(targetGroupBlockers) != 0 {
+    c.skipCleanup(report, "target group", c.targetGroupARN, fmt.Sprintf("it is still used by %s", strings.Join(targetGroupBlockers, " and ")))
+  } else {
+    c.cleanupTargetGroup(ctx, report)
+  }
+  if c.launchTemplateID != "" && !groupDeleted {
+    c.skipCleanup(report, "launch template", c.launchTemplateID, "it is still used by the auto scaling group")
+  } else {
+    c.cleanupLaunchTemplate(ctx, report)
+  }
+  c.cleanupAMI(ctx, report)
+  log.Printf("cleanup finished: %s", report)
+  c.cleanupReport = report
+  return report
+}
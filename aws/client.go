@@ -6,7 +6,11 @@ import (
   "github.com/aws/aws-sdk-go-v2/aws"
   "github.com/aws/aws-sdk-go-v2/config"
   "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
   "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "github.com/aws/aws-sdk-go-v2/service/route53"
+  route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
   "log"
   "regexp"
   "strings"
@@ -23,32 +27,72 @@ var (
 type RunConfig struct {
   InstanceID             string
   GroupName              string
+  Env                    string
+  NamingTemplates        NamingTemplates
   HealthPath             string
   DaemonPort             int32
   InstancesCount         int32
+  LifecycleHooks         []*LifecycleHook
+  WarmPool               *WarmPool
+  NotificationTopicARN   string
+  NotificationTypes      []string
+  NotifyURL              string
   HealthCheckGracePeriod time.Duration
+  TerminationPolicies    []string
+  ScaleInProtection      bool
+  MaxInstanceLifetime    time.Duration
+  DefaultCooldown        time.Duration
   UpdateTimeout          time.Duration
   UpdateTick             time.Duration
+  ExistingBalancer       string
+  LoadBalancerAttributes *LoadBalancerAttributes
+  ListenerPort           int32
+  HostHeader             string
+  PathPattern            string
+  DNSName                string
+  HostedZoneID           string
+  SmokeTest              bool
+  SmokeChecks            []*SmokeCheck
+  SmokeSuccesses         int
+  SmokeInterval          time.Duration
+  SmokeTimeout           time.Duration
+  SmokeCleanup           bool
+  ReportPath             string
+  Emit                   string
+  EmitFormat             TemplateFormat
+  RetryPolicy            RetryPolicy
+  names                  *artifactNames
 }
 
 func (c *RunConfig) GetGroupName() string {
-  return c.GroupName
+  return c.getNames().group
 }
 
 func (c *RunConfig) GetLaunchTemplateName() string {
-  return c.GroupName
+  return c.getNames().launchTemplate
 }
 
 func (c *RunConfig) GetTargetGroupName() string {
-  return strings.ReplaceAll(c.GroupName, "_", "-")
+  return c.getNames().targetGroup
 }
 
 func (c *RunConfig) GetBalancerName() string {
-  return strings.ReplaceAll(c.GroupName, "_", "-")
+  return c.getNames().balancer
 }
 
 func (c *RunConfig) GetAMIName() string {
-  return c.GroupName + " v1"
+  return c.getNames().ami
+}
+
+func (c *RunConfig) GetListenerPort() int32 {
+  if c.ListenerPort != 0 {
+    return c.ListenerPort
+  }
+  return c.DaemonPort
+}
+
+func (c *RunConfig) UsesExistingBalancer() bool {
+  return c.ExistingBalancer != ""
 }
 
 func validateELBName(name string, title string) error {
@@ -70,14 +114,40 @@ func validateELBName(name string, title string) error {
   return nil
 }
 
+func (c *RunConfig) HasDNSRecord() bool {
+  return c.DNSName != ""
+}
+
 func (c *RunConfig) ValidateArtifactNames() error {
-  if err := validateELBName(c.GetBalancerName(), "load balancer"); err != nil {
+  names, err := c.renderNames()
+  if err != nil {
+    return err
+  }
+  c.names = &names
+  if (c.DNSName == "") != (c.HostedZoneID == "") {
+    return fmt.Errorf("the DNS name and the hosted zone ID must be set up together")
+  }
+  if c.UsesExistingBalancer() {
+    if c.HostHeader == "" && c.PathPattern == "" {
+      return fmt.Errorf("attaching to the existing load balancer %q requires a host header or a path pattern for the listener rule", c.ExistingBalancer)
+    }
+  } else if err := validateELBName(c.GetBalancerName(), "load balancer"); err != nil {
     return err
   }
+  if c.LoadBalancerAttributes != nil {
+    if err := c.LoadBalancerAttributes.validate(); err != nil {
+      return err
+    }
+  }
   if err := validateELBName(c.GetTargetGroupName(), "target group"); err != nil {
     return err
   }
-  return nil
+  if c.SmokeTest {
+    if err := validateSmokeSuccesses(c.SmokeSuccesses); err != nil {
+      return err
+    }
+  }
+  return c.validateGroupSettings()
 }
 
 type Client struct {
@@ -87,26 +157,46 @@ type Client struct {
   loadBalancerName                string
   loadBalancerDNSName             string
   loadBalancerARN                 string
+  listenerRuleARN                 string
+  loadBalancerHostedZoneID        string
+  loadBalancerIPAddressType       elbtypes.IpAddressType
+  listenerProtocol                elbtypes.ProtocolEnum
+  dnsRecordTypes                  []route53types.RRType
+  previousDNSRecordSets           []route53types.ResourceRecordSet
   autoScalingGroupCreationStarted bool
+  buildSteps                      []*buildStep
+  cleanupReport                   *CleanupReport
+  startTime                       time.Time
+  events                          eventDispatcher
+  webhook                         *WebhookSink
 
   autoscalingClient *autoscaling.Client
+  cloudwatchClient  *cloudwatch.Client
   ec2Client         *ec2.Client
   elbClient         *elasticloadbalancingv2.Client
+  route53Client     *route53.Client
   rc                *RunConfig
-  ctx               context.Context
   region            string
 }
 
-func NewClient(ctx context.Context, rc *RunConfig) (*Client, error) {
+func LoadAWSConfig(ctx context.Context) (aws.Config, error) {
   awsConfig, err := config.LoadDefaultConfig(ctx)
   if err != nil {
-    return nil, fmt.Errorf("cannot load the AWS configuration: %v", err)
+    return aws.Config{}, fmt.Errorf("cannot load the AWS configuration: %w", err)
   }
+  return awsConfig, nil
+}
+
+func NewClient(awsConfig aws.Config, rc *RunConfig) *Client {
   return &Client{
     autoscalingClient: autoscaling.New(autoscaling.Options{
       Credentials: awsConfig.Credentials,
       Region:      awsConfig.Region,
     }),
+    cloudwatchClient: cloudwatch.New(cloudwatch.Options{
+      Credentials: awsConfig.Credentials,
+      Region:      awsConfig.Region,
+    }),
     ec2Client: ec2.New(ec2.Options{
       Credentials: awsConfig.Credentials,
       Region:      awsConfig.Region,
@@ -115,10 +205,23 @@ func NewClient(ctx context.Context, rc *RunConfig) (*Client, error) {
       Credentials: awsConfig.Credentials,
       Region:      awsConfig.Region,
     }),
-    rc:     rc,
-    ctx:    ctx,
-    region: awsConfig.Region,
-  }, nil
+    route53Client: route53.New(route53.Options{
+      Credentials: awsConfig.Credentials,
+      Region:      awsConfig.Region,
+    }),
+    rc:        rc,
+    region:    awsConfig.Region,
+    startTime: time.Now(),
+  }
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) error {
+  select {
+  case <-ctx.Done():
+    return ctx.Err()
+  case <-time.After(duration):
+    return nil
+  }
 }
 
 func (c *Client) GetAMILink(amiID string) string {
@@ -134,76 +237,47 @@ func (c *Client) GetTargetGroupLink(targetGroupArn string) string {
 }
 
 func (c *Client) GetLoadBalancerLink() string {
-  return fmt.Sprintf("https://console.aws.amazon.com/ec2/v2/home?region=%s#LoadBalancers:search=%s", c.region, c.rc.GetBalancerName())
+  return fmt.Sprintf("https://console.aws.amazon.com/ec2/v2/home?region=%s#LoadBalancers:search=%s", c.region, c.loadBalancerName)
 }
 
 func (c *Client) GetAutoScalingGroupLink() string {
   return fmt.Sprintf("https://console.aws.amazon.com/ec2autoscaling/home?region=%s#/details/%s", c.region, c.rc.GetGroupName())
 }
 
+func (c *Client) getServiceHost() string {
+  if len(c.dnsRecordTypes) != 0 {
+    return c.rc.DNSName
+  }
+  return c.loadBalancerDNSName
+}
+
+func (c *Client) getServiceScheme() string {
+  if c.listenerProtocol == elbtypes.ProtocolEnumHttps {
+    return "https"
+  }
+  return "http"
+}
+
+func (c *Client) getServiceURL() string {
+  return fmt.Sprintf("%s://%s:%d", c.getServiceScheme(), c.getServiceHost(), c.rc.GetListenerPort())
+}
+
+func (c *Client) getHealthURL() string {
+  return c.getServiceURL() + c.rc.HealthPath
+}
+
 func (c *Client) ReportCreatedArtifacts() {
   log.Printf("AMI link: %s", c.GetAMILink(c.amiID))
   log.Printf("Launch template link: %s", c.GetLaunchTemplateLink(c.launchTemplateID))
   log.Printf("Target group link: %s", c.GetTargetGroupLink(c.targetGroupARN))
   log.Printf("Balancer link: %s", c.GetLoadBalancerLink())
   log.Printf("Auto Scalingr group link: %s", c.GetAutoScalingGroupLink())
-  log.Printf("check out the health status: http://%s:%d%s", c.loadBalancerDNSName, c.rc.DaemonPort, c.rc.HealthPath)
-}
-
-func (c *Client) Cleanup() {
-  var errorMessages []string
-  if c.amiID != "" {
-    _, err := c.ec2Client.DeregisterImage(c.ctx, &ec2.DeregisterImageInput{
-      ImageId: aws.String(c.amiID),
-    })
-    if err != nil {
-      errorMessages = append(errorMessages, fmt.Sprintf("cannot deregister %q: %v", c.amiID, err))
-    } else {
-      log.Printf("deregistered %q", c.amiID)
-    }
-  }
-  if c.launchTemplateID != "" {
-    _, err := c.ec2Client.DeleteLaunchTemplate(c.ctx, &ec2.DeleteLaunchTemplateInput{
-      LaunchTemplateId: aws.String(c.launchTemplateID),
-    })
-    if err != nil {
-      errorMessages = append(errorMessages, fmt.Sprintf("cannot delete launch template %q: %v", c.launchTemplateID, err))
-    } else {
-      log.Printf("deleted launch template %q", c.launchTemplateID)
-    }
-  }
-  if c.targetGroupARN != "" {
-    _, err := c.elbClient.DeleteTargetGroup(c.ctx, &elasticloadbalancingv2.DeleteTargetGroupInput{
-      TargetGroupArn: aws.String(c.targetGroupARN),
-    })
-    if err != nil {
-      errorMessages = append(errorMessages, fmt.Sprintf("cannot delete target group %q: %v", c.targetGroupARN, err))
-    } else {
-      log.Printf("deleted target group %q", c.targetGroupARN)
-    }
-  }
-  if c.loadBalancerARN != "" {
-    _, err := c.elbClient.DeleteLoadBalancer(c.ctx, &elasticloadbalancingv2.DeleteLoadBalancerInput{
-      LoadBalancerArn: aws.String(c.loadBalancerARN),
-    })
-    if err != nil {
-      errorMessages = append(errorMessages, fmt.Sprintf("cannot delete load balancer %q: %v", c.loadBalancerName, err))
-    } else {
-      log.Printf("deleted load balancer %q", c.loadBalancerName)
-    }
-  }
-  if c.autoScalingGroupCreationStarted {
-    _, err := c.autoscalingClient.DeleteAutoScalingGroup(c.ctx, &autoscaling.DeleteAutoScalingGroupInput{
-      AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
-      ForceDelete:          aws.Bool(true),
-    })
-    if err != nil {
-      errorMessages = append(errorMessages, fmt.Sprintf("cannot delete the auto scaling group %q: %v", c.rc.GetGroupName(), err))
-    } else {
-      log.Printf("deleted auto scaling group %q", c.rc.GetGroupName())
-    }
+  if len(c.dnsRecordTypes) != 0 {
+    log.Printf("DNS name: %s", c.rc.DNSName)
   }
-  for _, errorMessage := range errorMessages {
-    log.Println(errorMessage)
+  if c.rc.HostHeader != "" {
+    log.Printf("check out the health status: %s (with the Host header %q)", c.getHealthURL(), c.rc.HostHeader)
+    return
   }
+  log.Printf("check out the health status: %s", c.getHealthURL())
 }
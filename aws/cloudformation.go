@@ -0,0 +1,550 @@
+package aws
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "regexp"
+  "strings"
+)
+
+type TemplateFormat string
+
+const EmitCloudFormation = "cloudformation"
+
+const (
+  TemplateFormatYAML TemplateFormat = "yaml"
+  TemplateFormatJSON TemplateFormat = "json"
+)
+
+var (
+  plainYAMLStringRegExp = regexp.MustCompile("^[A-Za-z/][A-Za-z0-9_./:<>, -]*$")
+  reservedYAMLWords     = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "null": true, "y": true, "n": true}
+)
+
+type cfnField struct {
+  key   string
+  value interface{}
+}
+
+type cfnMap []cfnField
+
+func marshalJSON(value interface{}, indent string) ([]byte, error) {
+  var buffer bytes.Buffer
+  encoder := json.NewEncoder(&buffer)
+  encoder.SetEscapeHTML(false)
+  encoder.SetIndent("", indent)
+  if err := encoder.Encode(value); err != nil {
+    return nil, err
+  }
+  return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
+
+func (m cfnMap) MarshalJSON() ([]byte, error) {
+  var buffer bytes.Buffer
+  buffer.WriteString("{")
+  for i, field := range m {
+    if i != 0 {
+      buffer.WriteString(",")
+    }
+    key, err := marshalJSON(field.key, "")
+    if err != nil {
+      return nil, err
+    }
+    value, err := marshalJSON(field.value, "")
+    if err != nil {
+      return nil, err
+    }
+    buffer.Write(key)
+    buffer.WriteString(":")
+    buffer.Write(value)
+  }
+  buffer.WriteString("}")
+  return buffer.Bytes(), nil
+}
+
+func cfnRef(name string) cfnMap {
+  return cfnMap{{"Ref", name}}
+}
+
+func cfnGetAtt(name string, attribute string) cfnMap {
+  return cfnMap{{"Fn::GetAtt", []interface{}{name, attribute}}}
+}
+
+func isPlainYAMLString(s string) bool {
+  if !plainYAMLStringRegExp.MatchString(s) || reservedYAMLWords[strings.ToLower(s)] {
+    return false
+  }
+  return !strings.HasSuffix(s, ":") && !strings.HasSuffix(s, " ") && !strings.Contains(s, ": ")
+}
+
+func formatYAMLScalar(value interface{}) string {
+  if s, ok := value.(string); ok && isPlainYAMLString(s) {
+    return s
+  }
+  encoded, err := marshalJSON(value, "")
+  if err != nil {
+    return fmt.Sprintf("%q", fmt.Sprint(value))
+  }
+  return string(encoded)
+}
+
+func writeYAML(builder *strings.Builder, value interface{}, indent int) {
+  prefix := strings.Repeat("  ", indent)
+  switch v := value.(type) {
+  case cfnMap:
+    for _, field := range v {
+      builder.WriteString(prefix + formatYAMLScalar(field.key) + ":")
+      writeYAMLNested(builder, field.value, indent+1)
+    }
+  case []interface{}:
+    for _, item := range v {
+      if _, ok := item.(cfnMap); !ok {
+        builder.WriteString(prefix + "- " + formatYAMLScalar(item) + "\n")
+        continue
+      }
+      var itemBuilder strings.Builder
+      writeYAML(&itemBuilder, item, indent+1)
+      builder.WriteString(prefix + "- " + strings.TrimPrefix(itemBuilder.String(), prefix+"  "))
+    }
+  }
+}
+
+func writeYAMLNested(builder *strings.Builder, value interface{}, indent int) {
+  switch v := value.(type) {
+  case cfnMap:
+    if len(v) == 0 {
+      builder.WriteString(" {}\n")
+      return
+    }
+    builder.WriteString("\n")
+    writeYAML(builder, v, indent)
+  case []interface{}:
+    if len(v) == 0 {
+      builder.WriteString(" []\n")
+      return
+    }
+    builder.WriteString("\n")
+    writeYAML(builder, v, indent)
+  default:
+    builder.WriteString(" " + formatYAMLScalar(v) + "\n")
+  }
+}
+
+type cloudFormationTarget struct {
+  instanceData     *ec2types.Instance
+  vpcID            string
+  subnetIDs        []string
+  listenerARN      string
+  rulePriority     int32
+  existingBalancer *elbtypes.LoadBalancer
+}
+
+func (c *Client) makeCloudFormationParameters(target *cloudFormationTarget) cfnMap {
+  parameters := cfnMap{
+    {"ImageId", cfnMap{
+      {"Type", "AWS::EC2::Image::Id"},
+      {"Description", fmt.Sprintf("the AMI to launch the instances from, e.g. registered from the instance %s", c.rc.InstanceID)},
+    }},
+    {"VpcId", cfnMap{
+      {"Type", "AWS::EC2::VPC::Id"},
+      {"Default", target.vpcID},
+    }},
+    {"Subnets", cfnMap{
+      {"Type", "List<AWS::EC2::Subnet::Id>"},
+      {"Default", strings.Join(target.subnetIDs, ",")},
+    }},
+    {"Port", cfnMap{
+      {"Type", "Number"},
+      {"Default", c.rc.DaemonPort},
+      {"Description", "the HTTP traffic port for the service"},
+    }},
+    {"InstancesCount", cfnMap{
+      {"Type", "Number"},
+      {"Default", c.rc.InstancesCount},
+      {"Description", "the min and desired number of instances in the group"},
+    }},
+    {"MaxInstancesCount", cfnMap{
+      {"Type", "Number"},
+      {"Default", 2 * c.rc.InstancesCount},
+      {"Description", "the max number of instances in the group"},
+    }},
+  }
+  if target.existingBalancer != nil {
+    parameters = append(parameters, cfnField{"ListenerRulePriority", cfnMap{
+      {"Type", "Number"},
+      {"Default", target.rulePriority},
+      {"MinValue", 1},
+      {"MaxValue", maxListenerRulePriority},
+      {"Description", "the priority of the listener rule; the default one was free when the template was generated"},
+    }})
+  }
+  return parameters
+}
+
+func makeCloudFormationPlacement(placement *ec2types.Placement) cfnMap {
+  var fields cfnMap
+  if placement == nil {
+    return fields
+  }
+  for _, field := range []struct {
+    name  string
+    value *string
+  }{
+    {"Affinity", placement.Affinity},
+    {"GroupName", placement.GroupName},
+    {"HostId", placement.HostId},
+    {"HostResourceGroupArn", placement.HostResourceGroupArn},
+    {"SpreadDomain", placement.SpreadDomain},
+  } {
+    if aws.ToString(field.value) != "" {
+      fields = append(fields, cfnField{field.name, *field.value})
+    }
+  }
+  if placement.PartitionNumber != nil {
+    fields = append(fields, cfnField{"PartitionNumber", *placement.PartitionNumber})
+  }
+  if placement.Tenancy != "" {
+    fields = append(fields, cfnField{"Tenancy", string(placement.Tenancy)})
+  }
+  return fields
+}
+
+func (c *Client) makeCloudFormationLaunchTemplate(instanceData *ec2types.Instance) cfnMap {
+  data := cfnMap{
+    {"ImageId", cfnRef("ImageId")},
+    {"InstanceType", string(instanceData.InstanceType)},
+    {"InstanceMarketOptions", cfnMap{{"MarketType", "spot"}}},
+  }
+  if instanceData.KeyName != nil {
+    data = append(data, cfnField{"KeyName", *instanceData.KeyName})
+  }
+  if instanceData.KernelId != nil {
+    data = append(data, cfnField{"KernelId", *instanceData.KernelId})
+  }
+  if placement := makeCloudFormationPlacement(instanceData.Placement); len(placement) != 0 {
+    data = append(data, cfnField{"Placement", placement})
+  }
+  var licenses []interface{}
+  for _, license := range instanceData.Licenses {
+    licenses = append(licenses, cfnMap{{"LicenseConfigurationArn", *license.LicenseConfigurationArn}})
+  }
+  if len(licenses) != 0 {
+    data = append(data, cfnField{"LicenseSpecifications", licenses})
+  }
+  return cfnMap{
+    {"Type", "AWS::EC2::LaunchTemplate"},
+    {"Properties", cfnMap{
+      {"LaunchTemplateName", c.rc.GetLaunchTemplateName()},
+      {"LaunchTemplateData", data},
+    }},
+  }
+}
+
+func (c *Client) makeCloudFormationTargetGroup() cfnMap {
+  return cfnMap{
+    {"Type", "AWS::ElasticLoadBalancingV2::TargetGroup"},
+    {"Properties", cfnMap{
+      {"Name", c.rc.GetTargetGroupName()},
+      {"HealthCheckEnabled", true},
+      {"HealthCheckPath", c.rc.HealthPath},
+      {"HealthCheckPort", cfnRef("Port")},
+      {"Protocol", string(elbtypes.ProtocolEnumHttp)},
+      {"Port", cfnRef("Port")},
+      {"VpcId", cfnRef("VpcId")},
+    }},
+  }
+}
+
+func (c *Client) getCloudFormationListenerPort() interface{} {
+  if c.rc.ListenerPort != 0 {
+    return c.rc.ListenerPort
+  }
+  return cfnRef("Port")
+}
+
+func (c *Client) makeCloudFormationLoadBalancerAttributes() []interface{} {
+  attributes := DefaultLoadBalancerAttributes()
+  if c.rc.LoadBalancerAttributes != nil {
+    attributes = *c.rc.LoadBalancerAttributes
+  }
+  var cfnAttributes []interface{}
+  for _, attribute := range attributes.makeAttributes() {
+    cfnAttributes = append(cfnAttributes, cfnMap{
+      {"Key", *attribute.Key},
+      {"Value", *attribute.Value},
+    })
+  }
+  return cfnAttributes
+}
+
+func (c *Client) makeCloudFormationBalancer() cfnMap {
+  return cfnMap{
+    {"LoadBalancer", cfnMap{
+      {"Type", "AWS::ElasticLoadBalancingV2::LoadBalancer"},
+      {"Properties", cfnMap{
+        {"Name", c.rc.GetBalancerName()},
+        {"Scheme", string(elbtypes.LoadBalancerSchemeEnumInternetFacing)},
+        {"Type", string(elbtypes.LoadBalancerTypeEnumApplication)},
+        {"Subnets", cfnRef("Subnets")},
+        {"LoadBalancerAttributes", c.makeCloudFormationLoadBalancerAttributes()},
+      }},
+    }},
+    {"Listener", cfnMap{
+      {"Type", "AWS::ElasticLoadBalancingV2::Listener"},
+      {"Properties", cfnMap{
+        {"LoadBalancerArn", cfnRef("LoadBalancer")},
+        {"Port", c.getCloudFormationListenerPort()},
+        {"Protocol", string(elbtypes.ProtocolEnumHttp)},
+        {"DefaultActions", []interface{}{
+          cfnMap{
+            {"Type", string(elbtypes.ActionTypeEnumForward)},
+            {"TargetGroupArn", cfnRef("TargetGroup")},
+          },
+        }},
+      }},
+    }},
+  }
+}
+
+func (c *Client) makeCloudFormationListenerRule(target *cloudFormationTarget) cfnMap {
+  var conditions []interface{}
+  if c.rc.HostHeader != "" {
+    conditions = append(conditions, cfnMap{
+      {"Field", "host-header"},
+      {"HostHeaderConfig", cfnMap{{"Values", []interface{}{c.rc.HostHeader}}}},
+    })
+  }
+  if c.rc.PathPattern != "" {
+    conditions = append(conditions, cfnMap{
+      {"Field", "path-pattern"},
+      {"PathPatternConfig", cfnMap{{"Values", []interface{}{c.rc.PathPattern}}}},
+    })
+  }
+  return cfnMap{
+    {"Type", "AWS::ElasticLoadBalancingV2::ListenerRule"},
+    {"Properties", cfnMap{
+      {"ListenerArn", target.listenerARN},
+      {"Priority", cfnRef("ListenerRulePriority")},
+      {"Conditions", conditions},
+      {"Actions", []interface{}{
+        cfnMap{
+          {"Type", string(elbtypes.ActionTypeEnumForward)},
+          {"TargetGroupArn", cfnRef("TargetGroup")},
+        },
+      }},
+    }},
+  }
+}
+
+func (c *Client) makeCloudFormationDNSRecords(target *cloudFormationTarget) cfnMap {
+  var dnsName, hostedZoneID interface{} = cfnGetAtt("LoadBalancer", "DNSName"), cfnGetAtt("LoadBalancer", "CanonicalHostedZoneID")
+  recordTypes := []string{"A"}
+  if target.existingBalancer != nil {
+    dnsName, hostedZoneID = *target.existingBalancer.DNSName, *target.existingBalancer.CanonicalHostedZoneId
+    if target.existingBalancer.IpAddressType == elbtypes.IpAddressTypeDualstack {
+      recordTypes = append(recordTypes, "AAAA")
+    }
+  }
+  records := cfnMap{}
+  for _, recordType := range recordTypes {
+    name := "DNSRecord"
+    if recordType != "A" {
+      name += recordType
+    }
+    records = append(records, cfnField{name, cfnMap{
+      {"Type", "AWS::Route53::RecordSet"},
+      {"Properties", cfnMap{
+        {"HostedZoneId", c.rc.HostedZoneID},
+        {"Name", c.rc.DNSName},
+        {"Type", recordType},
+        {"AliasTarget", cfnMap{
+          {"DNSName", dnsName},
+          {"HostedZoneId", hostedZoneID},
+          {"EvaluateTargetHealth", true},
+        }},
+      }},
+    }})
+  }
+  return records
+}
+
+func (c *Client) makeCloudFormationTags() []interface{} {
+  var tags []interface{}
+  for _, tag := range c.getAutoScalingGroupTags() {
+    tags = append(tags, cfnMap{
+      {"Key", *tag.Key},
+      {"Value", *tag.Value},
+      {"PropagateAtLaunch", *tag.PropagateAtLaunch},
+    })
+  }
+  return tags
+}
+
+func (c *Client) makeCloudFormationLifecycleHooks() []interface{} {
+  var hooks []interface{}
+  for _, specification := range c.makeLifecycleHookSpecifications() {
+    hook := cfnMap{
+      {"LifecycleHookName", *specification.LifecycleHookName},
+      {"LifecycleTransition", *specification.LifecycleTransition},
+      {"DefaultResult", *specification.DefaultResult},
+      {"HeartbeatTimeout", *specification.HeartbeatTimeout},
+    }
+    if specification.NotificationTargetARN != nil {
+      hook = append(hook, cfnField{"NotificationTargetARN", *specification.NotificationTargetARN}, cfnField{"RoleARN", *specification.RoleARN})
+    }
+    hooks = append(hooks, hook)
+  }
+  return hooks
+}
+
+func makeCloudFormationList(values []string) []interface{} {
+  var list []interface{}
+  for _, value := range values {
+    list = append(list, value)
+  }
+  return list
+}
+
+func (c *Client) makeCloudFormationAutoScalingGroup(dependsOn string) cfnMap {
+  properties := cfnMap{
+    {"AutoScalingGroupName", c.rc.GetGroupName()},
+    {"MinSize", cfnRef("InstancesCount")},
+    {"MaxSize", cfnRef("MaxInstancesCount")},
+    {"DesiredCapacity", cfnRef("InstancesCount")},
+    {"CapacityRebalance", true},
+    {"HealthCheckGracePeriod", int32(c.rc.HealthCheckGracePeriod.Seconds())},
+    {"HealthCheckType", "ELB"},
+    {"LaunchTemplate", cfnMap{
+      {"LaunchTemplateId", cfnRef("LaunchTemplate")},
+      {"Version", cfnGetAtt("LaunchTemplate", "LatestVersionNumber")},
+    }},
+    {"TargetGroupARNs", []interface{}{cfnRef("TargetGroup")}},
+    {"VPCZoneIdentifier", cfnRef("Subnets")},
+    {"MetricsCollection", []interface{}{cfnMap{{"Granularity", "1Minute"}}}},
+    {"Tags", c.makeCloudFormationTags()},
+    {"TerminationPolicies", makeCloudFormationList(c.rc.getTerminationPolicies())},
+    {"NewInstancesProtectedFromScaleIn", c.rc.ScaleInProtection},
+    {"Cooldown", fmt.Sprint(c.rc.getDefaultCooldown())},
+  }
+  if maxInstanceLifetime := c.rc.getMaxInstanceLifetime(); maxInstanceLifetime != nil {
+    properties = append(properties, cfnField{"MaxInstanceLifetime", *maxInstanceLifetime})
+  }
+  if len(c.rc.LifecycleHooks) != 0 {
+    properties = append(properties, cfnField{"LifecycleHookSpecificationList", c.makeCloudFormationLifecycleHooks()})
+  }
+  if c.rc.HasNotifications() {
+    properties = append(properties, cfnField{"NotificationConfigurations", []interface{}{cfnMap{
+      {"TopicARN", c.rc.NotificationTopicARN},
+      {"NotificationTypes", makeCloudFormationList(c.rc.NotificationTypes)},
+    }}})
+  }
+  return cfnMap{
+    {"Type", "AWS::AutoScaling::AutoScalingGroup"},
+    {"DependsOn", dependsOn},
+    {"Properties", properties},
+  }
+}
+
+func (c *Client) makeCloudFormationWarmPool() cfnMap {
+  warmPool := c.rc.WarmPool
+  return cfnMap{
+    {"Type", "AWS::AutoScaling::WarmPool"},
+    {"Properties", cfnMap{
+      {"AutoScalingGroupName", cfnRef("AutoScalingGroup")},
+      {"MinSize", warmPool.MinSize},
+      {"MaxGroupPreparedCapacity", warmPool.MaxPreparedCapacity},
+      {"PoolState", string(warmPool.PoolState)},
+      {"InstanceReusePolicy", cfnMap{
+        {"ReuseOnScaleIn", warmPool.ReuseOnScaleIn},
+      }},
+    }},
+  }
+}
+
+func (c *Client) makeCloudFormationTemplate(target *cloudFormationTarget) cfnMap {
+  resources := cfnMap{
+    {"LaunchTemplate", c.makeCloudFormationLaunchTemplate(target.instanceData)},
+    {"TargetGroup", c.makeCloudFormationTargetGroup()},
+  }
+  outputs := cfnMap{
+    {"AutoScalingGroupName", cfnMap{{"Value", cfnRef("AutoScalingGroup")}}},
+    {"TargetGroupArn", cfnMap{{"Value", cfnRef("TargetGroup")}}},
+  }
+  dependsOn := "Listener"
+  if target.existingBalancer != nil {
+    resources = append(resources, cfnField{"ListenerRule", c.makeCloudFormationListenerRule(target)})
+    outputs = append(outputs, cfnField{"LoadBalancerDNSName", cfnMap{{"Value", *target.existingBalancer.DNSName}}})
+    dependsOn = "ListenerRule"
+  } else {
+    resources = append(resources, c.makeCloudFormationBalancer()...)
+    outputs = append(outputs, cfnField{"LoadBalancerDNSName", cfnMap{{"Value", cfnGetAtt("LoadBalancer", "DNSName")}}})
+  }
+  if c.rc.HasDNSRecord() {
+    resources = append(resources, c.makeCloudFormationDNSRecords(target)...)
+    outputs = append(outputs, cfnField{"DNSName", cfnMap{{"Value", c.rc.DNSName}}})
+  }
+  resources = append(resources, cfnField{"AutoScalingGroup", c.makeCloudFormationAutoScalingGroup(dependsOn)})
+  if c.rc.HasWarmPool() {
+    resources = append(resources, cfnField{"WarmPool", c.makeCloudFormationWarmPool()})
+  }
+  return cfnMap{
+    {"AWSTemplateFormatVersion", "2010-09-09"},
+    {"Description", fmt.Sprintf("Auto Scaling group %s behind an application load balancer", c.rc.GetGroupName())},
+    {"Parameters", c.makeCloudFormationParameters(target)},
+    {"Resources", resources},
+    {"Outputs", outputs},
+  }
+}
+
+func formatTemplate(template cfnMap, format TemplateFormat) (string, error) {
+  switch format {
+  case TemplateFormatYAML:
+    var builder strings.Builder
+    writeYAML(&builder, template, 0)
+    return builder.String(), nil
+  case TemplateFormatJSON:
+    templateJSON, err := marshalJSON(template, "  ")
+    if err != nil {
+      return "", fmt.Errorf("cannot marshal the template: %w", err)
+    }
+    return string(templateJSON) + "\n", nil
+  }
+  return "", fmt.Errorf("unknown template format %q, expected %q or %q", format, TemplateFormatYAML, TemplateFormatJSON)
+}
+
+func (c *Client) MakeCloudFormationTemplate(ctx context.Context, format TemplateFormat) (string, error) {
+  instanceData, err := c.DescribeInstance(ctx)
+  if err != nil {
+    return "", err
+  }
+  target := &cloudFormationTarget{instanceData: instanceData}
+  target.vpcID, err = c.GetDefaultVPCID(ctx)
+  if err != nil {
+    return "", err
+  }
+  target.subnetIDs, err = c.GetSubnets(ctx, target.vpcID)
+  if err != nil {
+    return "", err
+  }
+  if c.rc.UsesExistingBalancer() {
+    target.existingBalancer, err = c.describeExistingLoadBalancer(ctx)
+    if err != nil {
+      return "", err
+    }
+    listener, err := c.findListener(ctx, *target.existingBalancer.LoadBalancerArn, c.rc.GetListenerPort())
+    if err != nil {
+      return "", err
+    }
+    target.listenerARN = *listener.ListenerArn
+    target.rulePriority, err = c.getFreeRulePriority(ctx, target.listenerARN)
+    if err != nil {
+      return "", err
+    }
+  }
+  return formatTemplate(c.makeCloudFormationTemplate(target), format)
+}
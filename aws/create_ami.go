@@ -1,6 +1,7 @@
 package aws
 
 import (
+  "context"
   "fmt"
   "github.com/aws/aws-sdk-go-v2/aws"
   "github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -9,14 +10,14 @@ import (
   "time"
 )
 
-func (c *Client) getImageState(imageID string) (types.ImageState, error) {
-  imagesDescription, err := c.ec2Client.DescribeImages(c.ctx, &ec2.DescribeImagesInput{
+func (c *Client) getImageState(ctx context.Context, imageID string) (types.ImageState, error) {
+  imagesDescription, err := c.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
     ImageIds: []string{
       imageID,
     },
   })
   if err != nil {
-    return "", fmt.Errorf("cannot get image description for AMI %s: %v", imageID, err)
+    return "", fmt.Errorf("cannot get image description for AMI %s: %w", imageID, err)
   }
   if len(imagesDescription.Images) != 1 {
     return "", fmt.Errorf("got %d != 1 images for image ID %s", len(imagesDescription.Images), imageID)
@@ -24,32 +25,81 @@ func (c *Client) getImageState(imageID string) (types.ImageState, error) {
   return imagesDescription.Images[0].State, nil
 }
 
-func (c *Client) CreateAMI() error {
-  createImageOutput, err := c.ec2Client.CreateImage(c.ctx, &ec2.CreateImageInput{
-    InstanceId: aws.String(c.rc.InstanceID),
-    Name:       aws.String(c.rc.GetAMIName()),
-    NoReboot:   aws.Bool(false),
+func (c *Client) findImageByName(ctx context.Context, name string) (string, error) {
+  res, err := c.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+    Owners: []string{"self"},
+    Filters: []types.Filter{
+      {
+        Name:   aws.String("name"),
+        Values: []string{name},
+      },
+    },
+  })
+  if err != nil {
+    return "", fmt.Errorf("cannot look up the image %q: %w", name, err)
+  }
+  if len(res.Images) == 0 {
+    return "", nil
+  }
+  return aws.ToString(res.Images[0].ImageId), nil
+}
+
+func (c *Client) CreateAMI(ctx context.Context) error {
+  var createImageOutput *ec2.CreateImageOutput
+  attempted := false
+  err := c.withRetries(ctx, "create an AMI", func() (err error) {
+    if attempted {
+      imageID, err := c.findImageByName(ctx, c.rc.GetAMIName())
+      if err != nil {
+        return err
+      }
+      if imageID != "" {
+        createImageOutput = &ec2.CreateImageOutput{ImageId: aws.String(imageID)}
+        return nil
+      }
+    }
+    attempted = true
+    createImageOutput, err = c.ec2Client.CreateImage(ctx, &ec2.CreateImageInput{
+      InstanceId: aws.String(c.rc.InstanceID),
+      Name:       aws.String(c.rc.GetAMIName()),
+      NoReboot:   aws.Bool(false),
+    }, withoutEC2Retries)
+    return err
   })
   if err != nil {
-    return fmt.Errorf("cannot create an AMI from instance %s: %v", c.rc.InstanceID, err)
+    return fmt.Errorf("cannot create an AMI from instance %s: %w", c.rc.InstanceID, err)
   }
+  c.amiID = *createImageOutput.ImageId
   finishTime := time.Now().Add(c.rc.UpdateTimeout)
   for time.Now().Before(finishTime) {
-    imageState, err := c.getImageState(*createImageOutput.ImageId)
+    imageState, err := c.getImageState(ctx, *createImageOutput.ImageId)
     if err != nil {
+      if !isRetryable(err) {
+        return err
+      }
       log.Printf("cannot get image state: %v", err)
-      time.Sleep(c.rc.UpdateTick)
+      if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+        return err
+      }
       continue
     }
-    log.Printf("%s (%q): %s", *createImageOutput.ImageId, c.rc.GetAMIName(), imageState)
+    c.emit(&Event{
+      Kind:         EventKindResourceState,
+      Step:         stepAMI,
+      ResourceType: "AMI",
+      ResourceID:   *createImageOutput.ImageId,
+      State:        string(imageState),
+      Message:      fmt.Sprintf("%s (%q): %s", *createImageOutput.ImageId, c.rc.GetAMIName(), imageState),
+    })
     if imageState != types.ImageStatePending {
       if imageState == types.ImageStateAvailable {
-        c.amiID = *createImageOutput.ImageId
         return nil
       }
       return fmt.Errorf("created image %s (%q) is in invalid state %s", *createImageOutput.ImageId, c.rc.GetAMIName(), imageState)
     }
-    time.Sleep(c.rc.UpdateTick)
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
   }
   return fmt.Errorf("the image %s (%q) didn't become available in %v", *createImageOutput.ImageId, c.rc.GetAMIName(), c.rc.UpdateTimeout)
 }
@@ -1,6 +1,8 @@
 package aws
 
 import (
+  "context"
+  "errors"
   "fmt"
   "github.com/aws/aws-sdk-go-v2/aws"
   "github.com/aws/aws-sdk-go-v2/service/autoscaling"
@@ -10,60 +12,141 @@ import (
   "time"
 )
 
-func (c *Client) CreateAutoScalingGroup(subnetIDs []string) error {
-  c.autoScalingGroupCreationStarted = true
-  _, err := c.autoscalingClient.CreateAutoScalingGroup(c.ctx, &autoscaling.CreateAutoScalingGroupInput{
-    AutoScalingGroupName:   aws.String(c.rc.GetGroupName()),
-    MaxSize:                aws.Int32(2 * c.rc.InstancesCount),
-    MinSize:                aws.Int32(c.rc.InstancesCount),
-    CapacityRebalance:      aws.Bool(true),
-    DesiredCapacity:        aws.Int32(c.rc.InstancesCount),
-    HealthCheckGracePeriod: aws.Int32(int32(c.rc.HealthCheckGracePeriod.Seconds())),
-    HealthCheckType:        aws.String("ELB"),
-    LaunchTemplate: &types.LaunchTemplateSpecification{
-      LaunchTemplateId: aws.String(c.launchTemplateID),
+const (
+  BuilderTagKey    = "aws-asg-builder"
+  BuilderEnvTagKey = "aws-asg-builder-env"
+)
+
+func (c *Client) getAutoScalingGroupTags() []types.Tag {
+  tags := []types.Tag{
+    {
+      Key:               aws.String(BuilderTagKey),
+      Value:             aws.String(c.rc.GroupName),
+      PropagateAtLaunch: aws.Bool(true),
     },
-    TargetGroupARNs:   []string{c.targetGroupARN},
-    VPCZoneIdentifier: aws.String(strings.Join(subnetIDs, ",")),
+  }
+  if c.rc.Env != "" {
+    tags = append(tags, types.Tag{
+      Key:               aws.String(BuilderEnvTagKey),
+      Value:             aws.String(c.rc.Env),
+      PropagateAtLaunch: aws.Bool(true),
+    })
+  }
+  return tags
+}
+
+func (c *Client) isOwnAutoScalingGroup(ctx context.Context) (bool, error) {
+  group, err := c.findAutoScalingGroup(ctx, c.rc.GetGroupName())
+  if err != nil || group == nil {
+    return false, err
+  }
+  launchTemplate := getGroupLaunchTemplate(group)
+  return launchTemplate != nil && aws.ToString(launchTemplate.LaunchTemplateId) == c.launchTemplateID, nil
+}
+
+func (c *Client) CreateAutoScalingGroup(ctx context.Context, subnetIDs []string) error {
+  c.autoScalingGroupCreationStarted = true
+  attempted := false
+  err := c.withRetries(ctx, "create an autoscaling group", func() error {
+    retry := attempted
+    attempted = true
+    _, err := c.autoscalingClient.CreateAutoScalingGroup(ctx, &autoscaling.CreateAutoScalingGroupInput{
+      AutoScalingGroupName:   aws.String(c.rc.GetGroupName()),
+      MaxSize:                aws.Int32(2 * c.rc.InstancesCount),
+      MinSize:                aws.Int32(c.rc.InstancesCount),
+      CapacityRebalance:      aws.Bool(true),
+      DesiredCapacity:        aws.Int32(c.rc.InstancesCount),
+      HealthCheckGracePeriod: aws.Int32(int32(c.rc.HealthCheckGracePeriod.Seconds())),
+      HealthCheckType:        aws.String("ELB"),
+      LaunchTemplate: &types.LaunchTemplateSpecification{
+        LaunchTemplateId: aws.String(c.launchTemplateID),
+      },
+      LifecycleHookSpecificationList:   c.makeLifecycleHookSpecifications(),
+      Tags:                             c.getAutoScalingGroupTags(),
+      TargetGroupARNs:                  []string{c.targetGroupARN},
+      VPCZoneIdentifier:                aws.String(strings.Join(subnetIDs, ",")),
+      TerminationPolicies:              c.rc.getTerminationPolicies(),
+      NewInstancesProtectedFromScaleIn: aws.Bool(c.rc.ScaleInProtection),
+      MaxInstanceLifetime:              c.rc.getMaxInstanceLifetime(),
+      DefaultCooldown:                  aws.Int32(c.rc.getDefaultCooldown()),
+    }, withoutAutoScalingRetries)
+    var alreadyExists *types.AlreadyExistsFault
+    if retry && errors.As(err, &alreadyExists) {
+      own, findErr := c.isOwnAutoScalingGroup(ctx)
+      if findErr != nil {
+        return findErr
+      }
+      if own {
+        log.Printf("the auto scaling group %q has been created by the previous attempt", c.rc.GetGroupName())
+        return nil
+      }
+    }
+    return err
   })
   if err != nil {
-    return fmt.Errorf("cannot create an autoscaling group: %v", err)
+    return fmt.Errorf("cannot create an autoscaling group: %w", err)
+  }
+  if c.rc.HasNotifications() {
+    if err := c.PutNotificationConfiguration(ctx); err != nil {
+      return err
+    }
   }
   finishTime := time.Now().Add(c.rc.UpdateTimeout)
   for time.Now().Before(finishTime) {
-    res, err := c.autoscalingClient.DescribeAutoScalingGroups(c.ctx, &autoscaling.DescribeAutoScalingGroupsInput{
-      AutoScalingGroupNames: []string{c.rc.GroupName},
+    res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+      AutoScalingGroupNames: []string{c.rc.GetGroupName()},
     })
     if err != nil {
-      log.Printf("cannot get description of the autoscaling group %q: %v", c.rc.GroupName, err)
-      time.Sleep(c.rc.UpdateTick)
+      if !isRetryable(err) {
+        return fmt.Errorf("cannot get description of the autoscaling group %q: %w", c.rc.GetGroupName(), err)
+      }
+      log.Printf("cannot get description of the autoscaling group %q: %v", c.rc.GetGroupName(), err)
+      if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+        return err
+      }
       continue
     }
     if len(res.AutoScalingGroups) != 1 {
-      return fmt.Errorf("received wrong %d != 1 number of auto scaling groups with name %q", len(res.AutoScalingGroups), c.rc.GroupName)
+      return fmt.Errorf("received wrong %d != 1 number of auto scaling groups with name %q", len(res.AutoScalingGroups), c.rc.GetGroupName())
     }
     numHealthy := int32(0)
     for _, instance := range res.AutoScalingGroups[0].Instances {
-      log.Printf("group %q, instance %q: %s, %s", c.rc.GroupName, *instance.InstanceId, instance.LifecycleState, *instance.HealthStatus)
+      c.emit(&Event{
+        Kind:         EventKindInstanceHealth,
+        Step:         stepAutoScalingGroup,
+        ResourceType: "instance",
+        ResourceID:   *instance.InstanceId,
+        State:        fmt.Sprintf("%s, %s", instance.LifecycleState, *instance.HealthStatus),
+        Message:      fmt.Sprintf("group %q, instance %q: %s, %s", c.rc.GetGroupName(), *instance.InstanceId, instance.LifecycleState, *instance.HealthStatus),
+      })
       if instance.LifecycleState == types.LifecycleStateInService && *instance.HealthStatus == "Healthy" {
         numHealthy++
       }
     }
-    log.Printf("group %q: %d instances in total, %d instances are in service and healthy (%d needed)", c.rc.GroupName, len(res.AutoScalingGroups[0].Instances), numHealthy, c.rc.InstancesCount)
+    log.Printf("group %q: %d instances in total, %d instances are in service and healthy (%d needed)", c.rc.GetGroupName(), len(res.AutoScalingGroups[0].Instances), numHealthy, c.rc.InstancesCount)
     if numHealthy >= c.rc.InstancesCount {
-      log.Printf("successfully created an auto scaling group %q", c.rc.GroupName)
-      _, err := c.autoscalingClient.EnableMetricsCollection(c.ctx, &autoscaling.EnableMetricsCollectionInput{
-        AutoScalingGroupName: aws.String(c.rc.GroupName),
+      c.emit(&Event{
+        Kind:         EventKindResourceState,
+        Step:         stepAutoScalingGroup,
+        ResourceType: "auto scaling group",
+        ResourceID:   c.rc.GetGroupName(),
+        State:        "ready",
+        Message:      fmt.Sprintf("successfully created an auto scaling group %q", c.rc.GetGroupName()),
+      })
+      _, err := c.autoscalingClient.EnableMetricsCollection(ctx, &autoscaling.EnableMetricsCollectionInput{
+        AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
         Granularity:          aws.String("1Minute"),
       })
       if err != nil {
-        log.Printf("cannot enable metrics collection for the group %q, consider adding them in the console manually", c.rc.GroupName)
+        log.Printf("cannot enable metrics collection for the group %q, consider adding them in the console manually", c.rc.GetGroupName())
         return nil
       }
-      log.Printf("enabled metrics collection for the group %q", c.rc.GroupName)
+      log.Printf("enabled metrics collection for the group %q", c.rc.GetGroupName())
       return nil
     }
-    time.Sleep(c.rc.UpdateTick)
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
   }
-  return fmt.Errorf("the autoscaling group %s has not become ready within the timeout %v", c.rc.GroupName, c.rc.UpdateTimeout)
+  return fmt.Errorf("the autoscaling group %s has not become ready within the timeout %v", c.rc.GetGroupName(), c.rc.UpdateTimeout)
 }
@@ -0,0 +1,160 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "github.com/aws/aws-sdk-go-v2/service/route53"
+  "github.com/aws/aws-sdk-go-v2/service/route53/types"
+  "log"
+  "strings"
+  "time"
+)
+
+func (c *Client) getDNSRecordTypes() []types.RRType {
+  if c.loadBalancerIPAddressType == elbtypes.IpAddressTypeDualstack {
+    return []types.RRType{types.RRTypeA, types.RRTypeAaaa}
+  }
+  return []types.RRType{types.RRTypeA}
+}
+
+func (c *Client) makeDNSRecordChanges(action types.ChangeAction, recordTypes []types.RRType) []types.Change {
+  var changes []types.Change
+  for _, recordType := range recordTypes {
+    changes = append(changes, types.Change{
+      Action: action,
+      ResourceRecordSet: &types.ResourceRecordSet{
+        Name: aws.String(c.rc.DNSName),
+        Type: recordType,
+        AliasTarget: &types.AliasTarget{
+          DNSName:              aws.String(c.loadBalancerDNSName),
+          HostedZoneId:         aws.String(c.loadBalancerHostedZoneID),
+          EvaluateTargetHealth: true,
+        },
+      },
+    })
+  }
+  return changes
+}
+
+func (c *Client) getChangeStatus(ctx context.Context, changeID string) (types.ChangeStatus, error) {
+  res, err := c.route53Client.GetChange(ctx, &route53.GetChangeInput{
+    Id: aws.String(changeID),
+  })
+  if err != nil {
+    return "", fmt.Errorf("cannot get the status of the DNS change %s: %w", changeID, err)
+  }
+  return res.ChangeInfo.Status, nil
+}
+
+func normalizeDNSName(name string) string {
+  return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func (c *Client) findDNSRecordSets(ctx context.Context) ([]types.ResourceRecordSet, error) {
+  var recordSets []types.ResourceRecordSet
+  input := &route53.ListResourceRecordSetsInput{
+    HostedZoneId:    aws.String(c.rc.HostedZoneID),
+    StartRecordName: aws.String(c.rc.DNSName),
+  }
+  for {
+    res, err := c.route53Client.ListResourceRecordSets(ctx, input)
+    if err != nil {
+      return nil, fmt.Errorf("cannot list the DNS records %q in the hosted zone %s: %w", c.rc.DNSName, c.rc.HostedZoneID, err)
+    }
+    for _, recordSet := range res.ResourceRecordSets {
+      if normalizeDNSName(aws.ToString(recordSet.Name)) != normalizeDNSName(c.rc.DNSName) {
+        return recordSets, nil
+      }
+      switch recordSet.Type {
+      case types.RRTypeA, types.RRTypeAaaa, types.RRTypeCname:
+        recordSets = append(recordSets, recordSet)
+      }
+    }
+    if !res.IsTruncated {
+      return recordSets, nil
+    }
+    input.StartRecordName = res.NextRecordName
+    input.StartRecordType = res.NextRecordType
+    input.StartRecordIdentifier = res.NextRecordIdentifier
+  }
+}
+
+func (c *Client) checkDNSRecordSets(recordSets []types.ResourceRecordSet) error {
+  for _, recordSet := range recordSets {
+    if recordSet.Type == types.RRTypeCname {
+      return fmt.Errorf("the DNS name %q has a CNAME record in the hosted zone %s, which cannot coexist with the alias records", c.rc.DNSName, c.rc.HostedZoneID)
+    }
+    if recordSet.SetIdentifier != nil {
+      return fmt.Errorf("the DNS name %q has a %s record with the routing policy %q in the hosted zone %s, which cannot be replaced with a simple alias record", c.rc.DNSName, recordSet.Type, aws.ToString(recordSet.SetIdentifier), c.rc.HostedZoneID)
+    }
+  }
+  return nil
+}
+
+func (c *Client) findPreviousDNSRecordSet(recordType types.RRType) *types.ResourceRecordSet {
+  for i := range c.previousDNSRecordSets {
+    if c.previousDNSRecordSets[i].Type == recordType {
+      return &c.previousDNSRecordSets[i]
+    }
+  }
+  return nil
+}
+
+func (c *Client) CreateDNSRecord(ctx context.Context) error {
+  recordTypes := c.getDNSRecordTypes()
+  previousRecordSets, err := c.findDNSRecordSets(ctx)
+  if err != nil {
+    return err
+  }
+  if err := c.checkDNSRecordSets(previousRecordSets); err != nil {
+    return err
+  }
+  c.previousDNSRecordSets = previousRecordSets
+  var res *route53.ChangeResourceRecordSetsOutput
+  err = c.withRetries(ctx, "upsert a DNS record", func() (err error) {
+    res, err = c.route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+      ChangeBatch: &types.ChangeBatch{
+        Changes: c.makeDNSRecordChanges(types.ChangeActionUpsert, recordTypes),
+        Comment: aws.String(fmt.Sprintf("alias for the load balancer of the auto scaling group %q", c.rc.GetGroupName())),
+      },
+      HostedZoneId: aws.String(c.rc.HostedZoneID),
+    }, withoutRoute53Retries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot upsert the DNS record %q in the hosted zone %s: %w", c.rc.DNSName, c.rc.HostedZoneID, err)
+  }
+  c.dnsRecordTypes = recordTypes
+  changeID := *res.ChangeInfo.Id
+  finishTime := time.Now().Add(c.rc.UpdateTimeout)
+  for time.Now().Before(finishTime) {
+    status, err := c.getChangeStatus(ctx, changeID)
+    if err != nil {
+      if !isRetryable(err) {
+        return err
+      }
+      log.Printf("cannot get DNS change status: %v", err)
+      if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+        return err
+      }
+      continue
+    }
+    c.emit(&Event{
+      Kind:         EventKindResourceState,
+      Step:         stepDNSRecord,
+      ResourceType: "DNS record",
+      ResourceID:   c.rc.DNSName,
+      State:        string(status),
+      Message:      fmt.Sprintf("DNS record %q: %s", c.rc.DNSName, status),
+    })
+    if status == types.ChangeStatusInsync {
+      return nil
+    }
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
+  }
+  return fmt.Errorf("the DNS record %q has not become in sync within the timeout %v", c.rc.DNSName, c.rc.UpdateTimeout)
+}
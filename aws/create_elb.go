@@ -1,6 +1,7 @@
 package aws
 
 import (
+  "context"
   "fmt"
   "github.com/aws/aws-sdk-go-v2/aws"
   "github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -8,38 +9,64 @@ import (
   "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
   "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
   "log"
-  "strings"
   "time"
 )
 
-func (c *Client) CreateTargetGroup(instanceDescription *ec2types.Instance) error {
-  targetGroupName := strings.ReplaceAll(c.rc.GroupName, "_", "-")
-  res, err := c.elbClient.CreateTargetGroup(c.ctx, &elasticloadbalancingv2.CreateTargetGroupInput{
-    Name:               aws.String(c.rc.GetTargetGroupName()),
-    HealthCheckEnabled: aws.Bool(true),
-    HealthCheckPath:    aws.String(c.rc.HealthPath),
-    HealthCheckPort:    aws.String(fmt.Sprintf("%d", c.rc.DaemonPort)),
-    Protocol:           types.ProtocolEnumHttp,
-    VpcId:              instanceDescription.VpcId,
-    Port:               aws.Int32(c.rc.DaemonPort),
+func (c *Client) makeForwardActions() []types.Action {
+  return []types.Action{
+    {
+      Type: types.ActionTypeEnumForward,
+      ForwardConfig: &types.ForwardActionConfig{
+        TargetGroups: []types.TargetGroupTuple{
+          {
+            TargetGroupArn: aws.String(c.targetGroupARN),
+            Weight:         aws.Int32(1),
+          },
+        },
+      },
+    },
+  }
+}
+
+func (c *Client) CreateTargetGroup(ctx context.Context, vpcID *string) error {
+  targetGroupName := c.rc.GetTargetGroupName()
+  var res *elasticloadbalancingv2.CreateTargetGroupOutput
+  err := c.withRetries(ctx, "register a target group", func() (err error) {
+    res, err = c.elbClient.CreateTargetGroup(ctx, &elasticloadbalancingv2.CreateTargetGroupInput{
+      Name:               aws.String(targetGroupName),
+      HealthCheckEnabled: aws.Bool(true),
+      HealthCheckPath:    aws.String(c.rc.HealthPath),
+      HealthCheckPort:    aws.String(fmt.Sprintf("%d", c.rc.DaemonPort)),
+      Protocol:           types.ProtocolEnumHttp,
+      VpcId:              vpcID,
+      Port:               aws.Int32(c.rc.DaemonPort),
+    }, withoutELBRetries)
+    return err
   })
   if err != nil {
-    return fmt.Errorf("cannot register the target group %q: %v", targetGroupName, err)
+    return fmt.Errorf("cannot register the target group %q: %w", targetGroupName, err)
   }
   if len(res.TargetGroups) != 1 {
     return fmt.Errorf("created wrong %d != 1 number of target groups with name %q", len(res.TargetGroups), targetGroupName)
   }
-  log.Printf("created target group %q (%s)", targetGroupName, *res.TargetGroups[0].TargetGroupArn)
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepTargetGroup,
+    ResourceType: "target group",
+    ResourceID:   *res.TargetGroups[0].TargetGroupArn,
+    State:        "created",
+    Message:      fmt.Sprintf("created target group %q (%s)", targetGroupName, *res.TargetGroups[0].TargetGroupArn),
+  })
   c.targetGroupARN = *res.TargetGroups[0].TargetGroupArn
   return nil
 }
 
-func (c *Client) GetDefaultVPCID() (string, error) {
+func (c *Client) GetDefaultVPCID(ctx context.Context) (string, error) {
   var nextToken *string
   for {
-    vpcRes, err := c.ec2Client.DescribeVpcs(c.ctx, &ec2.DescribeVpcsInput{NextToken: nextToken})
+    vpcRes, err := c.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{NextToken: nextToken})
     if err != nil {
-      return "", fmt.Errorf("cannot describe VPCs: %v", err)
+      return "", fmt.Errorf("cannot describe VPCs: %w", err)
     }
     for _, vpc := range vpcRes.Vpcs {
       if *vpc.IsDefault && vpc.State != ec2types.VpcStateAvailable {
@@ -57,15 +84,15 @@ func (c *Client) GetDefaultVPCID() (string, error) {
   return "", nil
 }
 
-func (c *Client) GetSubnets(defaultVPCID string) ([]string, error) {
+func (c *Client) GetSubnets(ctx context.Context, defaultVPCID string) ([]string, error) {
   var nextToken *string
   var subnetIDs []string
   for {
-    subnets, err := c.ec2Client.DescribeSubnets(c.ctx, &ec2.DescribeSubnetsInput{
+    subnets, err := c.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
       NextToken: nextToken,
     })
     if err != nil {
-      return nil, fmt.Errorf("cannot read the list of subnets: %v", err)
+      return nil, fmt.Errorf("cannot read the list of subnets: %w", err)
     }
     for _, s := range subnets.Subnets {
       if *s.VpcId == defaultVPCID && *s.DefaultForAz {
@@ -80,66 +107,87 @@ func (c *Client) GetSubnets(defaultVPCID string) ([]string, error) {
   return subnetIDs, nil
 }
 
-func (c *Client) CreateLoadBalancer(subnetIDs []string) error {
-  balancerName := strings.ReplaceAll(c.rc.GroupName, "_", "-")
-  createLoadBalancerRes, err := c.elbClient.CreateLoadBalancer(c.ctx, &elasticloadbalancingv2.CreateLoadBalancerInput{
-    Name:    aws.String(c.rc.GetBalancerName()),
-    Scheme:  types.LoadBalancerSchemeEnumInternetFacing,
-    Type:    types.LoadBalancerTypeEnumApplication,
-    Subnets: subnetIDs,
+func (c *Client) createListener(ctx context.Context, loadBalancerARN string) error {
+  err := c.withRetries(ctx, "create a listener", func() error {
+    _, err := c.elbClient.CreateListener(ctx, &elasticloadbalancingv2.CreateListenerInput{
+      DefaultActions:  c.makeForwardActions(),
+      LoadBalancerArn: aws.String(loadBalancerARN),
+      Port:            aws.Int32(c.rc.GetListenerPort()),
+      Protocol:        types.ProtocolEnumHttp,
+    }, withoutELBRetries)
+    return err
   })
   if err != nil {
-    return fmt.Errorf("cannot create an elastic load balancer %q: %v", balancerName, err)
+    return fmt.Errorf("cannot crate listener for the load balancer %q: %w", c.rc.GetBalancerName(), err)
+  }
+  return nil
+}
+
+func (c *Client) CreateLoadBalancer(ctx context.Context, subnetIDs []string) error {
+  balancerName := c.rc.GetBalancerName()
+  var createLoadBalancerRes *elasticloadbalancingv2.CreateLoadBalancerOutput
+  err := c.withRetries(ctx, "create a load balancer", func() (err error) {
+    createLoadBalancerRes, err = c.elbClient.CreateLoadBalancer(ctx, &elasticloadbalancingv2.CreateLoadBalancerInput{
+      Name:    aws.String(balancerName),
+      Scheme:  types.LoadBalancerSchemeEnumInternetFacing,
+      Type:    types.LoadBalancerTypeEnumApplication,
+      Subnets: subnetIDs,
+    }, withoutELBRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot create an elastic load balancer %q: %w", balancerName, err)
   }
   if len(createLoadBalancerRes.LoadBalancers) != 1 {
     return fmt.Errorf("created wrong %d != 1 number of load balancers with name %q", len(createLoadBalancerRes.LoadBalancers), balancerName)
   }
+  c.loadBalancerName = balancerName
+  c.loadBalancerARN = *createLoadBalancerRes.LoadBalancers[0].LoadBalancerArn
   finishTime := time.Now().Add(c.rc.UpdateTimeout)
   for time.Now().Before(finishTime) {
-    describeLoadBalancersRes, err := c.elbClient.DescribeLoadBalancers(c.ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+    describeLoadBalancersRes, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
       LoadBalancerArns: []string{*createLoadBalancerRes.LoadBalancers[0].LoadBalancerArn},
     })
     if err != nil {
+      if !isRetryable(err) {
+        return fmt.Errorf("cannot get description of the balancer %q: %w", balancerName, err)
+      }
       log.Printf("cannot get description of the balancer %q: %v", balancerName, err)
-      time.Sleep(c.rc.UpdateTick)
+      if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+        return err
+      }
       continue
     }
     if len(describeLoadBalancersRes.LoadBalancers) != 1 {
       return fmt.Errorf("received wrong %d != 1 number of load balancers with arn %s", len(describeLoadBalancersRes.LoadBalancers), *createLoadBalancerRes.LoadBalancers[0].LoadBalancerArn)
     }
     state := describeLoadBalancersRes.LoadBalancers[0].State.Code
-    log.Printf("load balancer %q: %s", balancerName, state)
+    c.emit(&Event{
+      Kind:         EventKindResourceState,
+      Step:         stepLoadBalancer,
+      ResourceType: "load balancer",
+      ResourceID:   c.loadBalancerARN,
+      State:        string(state),
+      Message:      fmt.Sprintf("load balancer %q: %s", balancerName, state),
+    })
     if state != types.LoadBalancerStateEnumProvisioning {
       if state != types.LoadBalancerStateEnumActive {
         return fmt.Errorf("load balancer ended up not in an active status %q", state)
       }
-      _, err := c.elbClient.CreateListener(c.ctx, &elasticloadbalancingv2.CreateListenerInput{
-        DefaultActions: []types.Action{
-          {
-            Type: types.ActionTypeEnumForward,
-            ForwardConfig: &types.ForwardActionConfig{
-              TargetGroups: []types.TargetGroupTuple{
-                {
-                  TargetGroupArn: aws.String(c.targetGroupARN),
-                  Weight:         aws.Int32(1),
-                },
-              },
-            },
-          },
-        },
-        LoadBalancerArn: createLoadBalancerRes.LoadBalancers[0].LoadBalancerArn,
-        Port:            aws.Int32(c.rc.DaemonPort),
-        Protocol:        types.ProtocolEnumHttp,
-      })
-      if err != nil {
-        return fmt.Errorf("cannot crate listener for the load balancer %q: %v", balancerName, err)
+      if err := c.applyLoadBalancerAttributes(ctx, c.loadBalancerARN); err != nil {
+        return err
+      }
+      if err := c.createListener(ctx, *createLoadBalancerRes.LoadBalancers[0].LoadBalancerArn); err != nil {
+        return err
       }
-      c.loadBalancerName = *describeLoadBalancersRes.LoadBalancers[0].LoadBalancerName
       c.loadBalancerDNSName = *describeLoadBalancersRes.LoadBalancers[0].DNSName
-      c.loadBalancerARN = *describeLoadBalancersRes.LoadBalancers[0].LoadBalancerArn
+      c.loadBalancerHostedZoneID = *describeLoadBalancersRes.LoadBalancers[0].CanonicalHostedZoneId
+      c.loadBalancerIPAddressType = describeLoadBalancersRes.LoadBalancers[0].IpAddressType
       return nil
     }
-    time.Sleep(c.rc.UpdateTick)
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
   }
   return fmt.Errorf("the balancer %q has not become ready within the timeout %v", balancerName, c.rc.UpdateTimeout)
 }
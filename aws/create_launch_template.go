@@ -1,22 +1,22 @@
 package aws
 
 import (
+  "context"
   "encoding/json"
   "fmt"
   "github.com/aws/aws-sdk-go-v2/aws"
   "github.com/aws/aws-sdk-go-v2/service/ec2"
   "github.com/aws/aws-sdk-go-v2/service/ec2/types"
-  "log"
 )
 
 func extractLicenseSpecifications(instanceData *types.Instance) ([]types.LaunchTemplateLicenseConfigurationRequest, error) {
   licenseSpecificationsJson, err := json.Marshal(instanceData.Licenses)
   if err != nil {
-    return nil, fmt.Errorf("cannot marshal license specifications of the instance with id %s: %v", *instanceData.InstanceId, err)
+    return nil, fmt.Errorf("cannot marshal license specifications of the instance with id %s: %w", *instanceData.InstanceId, err)
   }
   var licenseSpecificationsRequests []types.LaunchTemplateLicenseConfigurationRequest
   if err := json.Unmarshal(licenseSpecificationsJson, &licenseSpecificationsRequests); err != nil {
-    return nil, fmt.Errorf("cannot unmarshal license specifications of the instance with id %s: %v", *instanceData.InstanceId, err)
+    return nil, fmt.Errorf("cannot unmarshal license specifications of the instance with id %s: %w", *instanceData.InstanceId, err)
   }
   return licenseSpecificationsRequests, nil
 }
@@ -24,11 +24,11 @@ func extractLicenseSpecifications(instanceData *types.Instance) ([]types.LaunchT
 func extractPlacement(instanceData *types.Instance) (*types.LaunchTemplatePlacementRequest, error) {
   placementJson, err := json.Marshal(instanceData.Placement)
   if err != nil {
-    return nil, fmt.Errorf("cannot marshal placement of the instance with id %s: %v", *instanceData.InstanceId, err)
+    return nil, fmt.Errorf("cannot marshal placement of the instance with id %s: %w", *instanceData.InstanceId, err)
   }
   placementRequest := &types.LaunchTemplatePlacementRequest{}
   if err := json.Unmarshal(placementJson, &placementRequest); err != nil {
-    return nil, fmt.Errorf("cannot unmarshal placement of the instance with id %s: %v", *instanceData.InstanceId, err)
+    return nil, fmt.Errorf("cannot unmarshal placement of the instance with id %s: %w", *instanceData.InstanceId, err)
   }
   placementRequest.AvailabilityZone = nil
   return placementRequest, nil
@@ -56,19 +56,42 @@ func (c *Client) generateLaunchTemplateData(amiID string, instanceData *types.In
   }, nil
 }
 
-func (c *Client) CreateLaunchTemplate(instanceData *types.Instance) error {
+const maxClientTokenLength = 128
+
+func (c *Client) makeClientToken(name string) string {
+  token := fmt.Sprintf("%d-%s", c.startTime.UnixNano(), name)
+  if len(token) > maxClientTokenLength {
+    token = token[:maxClientTokenLength]
+  }
+  return token
+}
+
+func (c *Client) CreateLaunchTemplate(ctx context.Context, instanceData *types.Instance) error {
   launchTemplateData, err := c.generateLaunchTemplateData(c.amiID, instanceData)
   if err != nil {
-    return fmt.Errorf("cannot generate launch template data from ami %s: %v", c.amiID, err)
+    return fmt.Errorf("cannot generate launch template data from ami %s: %w", c.amiID, err)
   }
-  res, err := c.ec2Client.CreateLaunchTemplate(c.ctx, &ec2.CreateLaunchTemplateInput{
-    LaunchTemplateData: launchTemplateData,
-    LaunchTemplateName: aws.String(c.rc.GetLaunchTemplateName()),
+  var res *ec2.CreateLaunchTemplateOutput
+  clientToken := c.makeClientToken(c.rc.GetLaunchTemplateName())
+  err = c.withRetries(ctx, "create a launch template", func() (err error) {
+    res, err = c.ec2Client.CreateLaunchTemplate(ctx, &ec2.CreateLaunchTemplateInput{
+      ClientToken:        aws.String(clientToken),
+      LaunchTemplateData: launchTemplateData,
+      LaunchTemplateName: aws.String(c.rc.GetLaunchTemplateName()),
+    }, withoutEC2Retries)
+    return err
   })
   if err != nil {
-    return fmt.Errorf("cannot create launch template from ami %s: %v", c.amiID, err)
+    return fmt.Errorf("cannot create launch template from ami %s: %w", c.amiID, err)
   }
-  log.Printf("created launch template %q", c.rc.GroupName)
   c.launchTemplateID = *res.LaunchTemplate.LaunchTemplateId
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepLaunchTemplate,
+    ResourceType: "launch template",
+    ResourceID:   c.launchTemplateID,
+    State:        "created",
+    Message:      fmt.Sprintf("created launch template %q", c.rc.GetLaunchTemplateName()),
+  })
   return nil
 }
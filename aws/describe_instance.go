@@ -1,13 +1,14 @@
 package aws
 
 import (
+  "context"
   "fmt"
   "github.com/aws/aws-sdk-go-v2/service/ec2"
   "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
-func (c *Client) DescribeInstance() (*types.Instance, error) {
-  describedInstances, err := c.ec2Client.DescribeInstances(c.ctx, &ec2.DescribeInstancesInput{
+func (c *Client) DescribeInstance(ctx context.Context) (*types.Instance, error) {
+  describedInstances, err := c.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
     InstanceIds: []string{c.rc.InstanceID},
   })
   if err != nil {
@@ -0,0 +1,401 @@
+package aws
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "io"
+  "sort"
+  "strings"
+)
+
+const (
+  resourceAutoScalingGroup    = "auto scaling group"
+  resourceLaunchTemplate      = "launch template"
+  resourceTargetGroup         = "target group"
+  resourceLoadBalancer        = "load balancer"
+  resourceSharedLoadBalancers = "shared load balancers"
+  resourceListener            = "listener"
+  resourceListenerRule        = "listener rule"
+  resourceLifecycleHook       = "lifecycle hook"
+  resourceWarmPool            = "warm pool"
+  resourceNotifications       = "notifications"
+)
+
+type Drift struct {
+  ResourceType string `json:"resource_type"`
+  Resource     string `json:"resource"`
+  Field        string `json:"field"`
+  Desired      string `json:"desired"`
+  Actual       string `json:"actual"`
+}
+
+type driftCollector struct {
+  drifts []*Drift
+}
+
+func (d *driftCollector) compare(resourceType string, resource string, field string, desired interface{}, actual interface{}) {
+  desiredValue := fmt.Sprint(desired)
+  actualValue := fmt.Sprint(actual)
+  if desiredValue != actualValue {
+    d.drifts = append(d.drifts, &Drift{
+      ResourceType: resourceType,
+      Resource:     resource,
+      Field:        field,
+      Desired:      desiredValue,
+      Actual:       actualValue,
+    })
+  }
+}
+
+func (d *driftCollector) missing(resourceType string, resource string, field string, desired string) {
+  d.compare(resourceType, resource, field, desired, "none")
+}
+
+func hasDrift(drifts []*Drift, resourceType string) bool {
+  for _, drift := range drifts {
+    if drift.ResourceType == resourceType {
+      return true
+    }
+  }
+  return false
+}
+
+func joinOrNone(values []string) string {
+  if len(values) == 0 {
+    return "none"
+  }
+  sort.Strings(values)
+  return strings.Join(values, ", ")
+}
+
+func (s *Stack) findTargetGroup(name string) *elbtypes.TargetGroup {
+  for i := range s.TargetGroups {
+    if aws.ToString(s.TargetGroups[i].TargetGroupName) == name {
+      return &s.TargetGroups[i]
+    }
+  }
+  return nil
+}
+
+func (s *Stack) findLoadBalancer(name string) *elbtypes.LoadBalancer {
+  for i := range s.LoadBalancers {
+    if aws.ToString(s.LoadBalancers[i].LoadBalancerName) == name {
+      return &s.LoadBalancers[i]
+    }
+  }
+  return nil
+}
+
+func (c *Client) findServingLoadBalancer(stack *Stack) *elbtypes.LoadBalancer {
+  if loadBalancer := stack.findLoadBalancer(c.rc.GetBalancerName()); loadBalancer != nil {
+    return loadBalancer
+  }
+  for i := range stack.LoadBalancers {
+    if stack.findListener(*stack.LoadBalancers[i].LoadBalancerArn, c.rc.GetListenerPort()) != nil {
+      return &stack.LoadBalancers[i]
+    }
+  }
+  if len(stack.LoadBalancers) == 1 {
+    return &stack.LoadBalancers[0]
+  }
+  return nil
+}
+
+func (s *Stack) findListener(loadBalancerARN string, port int32) *elbtypes.Listener {
+  for i := range s.Listeners {
+    if aws.ToString(s.Listeners[i].LoadBalancerArn) == loadBalancerARN && aws.ToInt32(s.Listeners[i].Port) == port {
+      return &s.Listeners[i]
+    }
+  }
+  return nil
+}
+
+func (s *Stack) findListenerRule(port int32) *StackListenerRule {
+  for i := range s.ListenerRules {
+    if s.ListenerRules[i].ListenerPort == port {
+      return &s.ListenerRules[i]
+    }
+  }
+  return nil
+}
+
+func (s *Stack) getTargetGroupNames(targetGroupARNs []string) []string {
+  var names []string
+  for _, targetGroupARN := range targetGroupARNs {
+    name := targetGroupARN
+    for _, targetGroup := range s.TargetGroups {
+      if *targetGroup.TargetGroupArn == targetGroupARN {
+        name = aws.ToString(targetGroup.TargetGroupName)
+      }
+    }
+    names = append(names, name)
+  }
+  return names
+}
+
+func (c *Client) diffAutoScalingGroup(d *driftCollector, stack *Stack) {
+  resource := fmt.Sprintf("auto scaling group %q", c.rc.GetGroupName())
+  group := stack.Group
+  d.compare(resourceAutoScalingGroup, resource, "min size", c.rc.InstancesCount, aws.ToInt32(group.MinSize))
+  d.compare(resourceAutoScalingGroup, resource, "max size", 2*c.rc.InstancesCount, aws.ToInt32(group.MaxSize))
+  d.compare(resourceAutoScalingGroup, resource, "desired capacity", c.rc.InstancesCount, aws.ToInt32(group.DesiredCapacity))
+  d.compare(resourceAutoScalingGroup, resource, "health check type", "ELB", aws.ToString(group.HealthCheckType))
+  d.compare(resourceAutoScalingGroup, resource, "health check grace period", int32(c.rc.HealthCheckGracePeriod.Seconds()), aws.ToInt32(group.HealthCheckGracePeriod))
+  d.compare(resourceAutoScalingGroup, resource, "capacity rebalance", true, aws.ToBool(group.CapacityRebalance))
+  d.compare(resourceAutoScalingGroup, resource, "termination policies", strings.Join(c.rc.getTerminationPolicies(), ", "), strings.Join(group.TerminationPolicies, ", "))
+  d.compare(resourceAutoScalingGroup, resource, "scale-in protection", c.rc.ScaleInProtection, aws.ToBool(group.NewInstancesProtectedFromScaleIn))
+  d.compare(resourceAutoScalingGroup, resource, "max instance lifetime", formatMaxInstanceLifetime(aws.ToInt32(c.rc.getMaxInstanceLifetime())), formatMaxInstanceLifetime(aws.ToInt32(group.MaxInstanceLifetime)))
+  d.compare(resourceAutoScalingGroup, resource, "default cooldown", c.rc.getDefaultCooldown(), aws.ToInt32(group.DefaultCooldown))
+  launchTemplateName := "none"
+  if stack.LaunchTemplateVersion != nil {
+    launchTemplateName = aws.ToString(stack.LaunchTemplateVersion.LaunchTemplateName)
+  }
+  d.compare(resourceLaunchTemplate, resource, "launch template", c.rc.GetLaunchTemplateName(), launchTemplateName)
+  targetGroupNames := stack.getTargetGroupNames(group.TargetGroupARNs)
+  for _, name := range targetGroupNames {
+    if name == c.rc.GetTargetGroupName() {
+      return
+    }
+  }
+  d.compare(resourceTargetGroup, resource, "target groups", c.rc.GetTargetGroupName(), joinOrNone(targetGroupNames))
+}
+
+func (c *Client) diffLaunchTemplate(d *driftCollector, stack *Stack) {
+  if stack.LaunchTemplateVersion == nil {
+    return
+  }
+  resource := fmt.Sprintf("launch template %q", aws.ToString(stack.LaunchTemplateVersion.LaunchTemplateName))
+  marketType := "none"
+  if data := stack.GetLaunchTemplateData(); data.InstanceMarketOptions != nil {
+    marketType = string(data.InstanceMarketOptions.MarketType)
+  }
+  d.compare(resourceLaunchTemplate, resource, "market type", "spot", marketType)
+}
+
+func (c *Client) diffTargetGroup(d *driftCollector, targetGroup *elbtypes.TargetGroup) {
+  resource := fmt.Sprintf("target group %q", c.rc.GetTargetGroupName())
+  d.compare(resourceTargetGroup, resource, "port", c.rc.DaemonPort, aws.ToInt32(targetGroup.Port))
+  d.compare(resourceTargetGroup, resource, "protocol", elbtypes.ProtocolEnumHttp, targetGroup.Protocol)
+  d.compare(resourceTargetGroup, resource, "health check enabled", true, aws.ToBool(targetGroup.HealthCheckEnabled))
+  d.compare(resourceTargetGroup, resource, "health check path", c.rc.HealthPath, aws.ToString(targetGroup.HealthCheckPath))
+  d.compare(resourceTargetGroup, resource, "health check port", c.rc.DaemonPort, aws.ToString(targetGroup.HealthCheckPort))
+}
+
+func (c *Client) diffListener(d *driftCollector, stack *Stack) {
+  loadBalancer := c.findServingLoadBalancer(stack)
+  if loadBalancer == nil {
+    d.missing(resourceLoadBalancer, fmt.Sprintf("load balancer %q", c.rc.GetBalancerName()), "forwarding to the target group", c.rc.GetTargetGroupName())
+    return
+  }
+  loadBalancerName := aws.ToString(loadBalancer.LoadBalancerName)
+  if c.rc.LoadBalancerAttributes != nil {
+    attributes := stack.LoadBalancerAttributes[*loadBalancer.LoadBalancerArn]
+    for _, attribute := range c.rc.LoadBalancerAttributes.makeAttributes() {
+      actual, ok := attributes[*attribute.Key]
+      if !ok {
+        actual = "none"
+      }
+      d.compare(resourceLoadBalancer, fmt.Sprintf("load balancer %q", loadBalancerName), *attribute.Key, *attribute.Value, actual)
+    }
+  }
+  resource := fmt.Sprintf("listener %q:%d", loadBalancerName, c.rc.GetListenerPort())
+  listener := stack.findListener(*loadBalancer.LoadBalancerArn, c.rc.GetListenerPort())
+  if listener == nil {
+    d.missing(resourceListener, resource, "forwards to", c.rc.GetTargetGroupName())
+    return
+  }
+  d.compare(resourceListener, resource, "protocol", elbtypes.ProtocolEnumHttp, listener.Protocol)
+  d.compare(resourceListener, resource, "forwards to", c.rc.GetTargetGroupName(), joinOrNone(stack.getTargetGroupNames(getForwardTargetGroupARNs(listener.DefaultActions))))
+}
+
+func getRuleConditionValues(rule *elbtypes.Rule, field string) []string {
+  var values []string
+  for _, condition := range rule.Conditions {
+    if aws.ToString(condition.Field) != field {
+      continue
+    }
+    switch {
+    case condition.HostHeaderConfig != nil:
+      values = append(values, condition.HostHeaderConfig.Values...)
+    case condition.PathPatternConfig != nil:
+      values = append(values, condition.PathPatternConfig.Values...)
+    default:
+      values = append(values, condition.Values...)
+    }
+  }
+  return values
+}
+
+func (c *Client) diffListenerRule(d *driftCollector, stack *Stack) {
+  resource := fmt.Sprintf("listener rule of %q:%d", c.rc.ExistingBalancer, c.rc.GetListenerPort())
+  var loadBalancerNames []string
+  for _, loadBalancer := range stack.SharedLoadBalancers {
+    if aws.ToString(loadBalancer.LoadBalancerArn) == c.rc.ExistingBalancer {
+      loadBalancerNames = append(loadBalancerNames, c.rc.ExistingBalancer)
+      continue
+    }
+    loadBalancerNames = append(loadBalancerNames, aws.ToString(loadBalancer.LoadBalancerName))
+  }
+  d.compare(resourceSharedLoadBalancers, fmt.Sprintf("target group %q", c.rc.GetTargetGroupName()), "load balancers", c.rc.ExistingBalancer, joinOrNone(loadBalancerNames))
+  listenerRule := stack.findListenerRule(c.rc.GetListenerPort())
+  if listenerRule == nil {
+    d.missing(resourceListenerRule, resource, "forwards to", c.rc.GetTargetGroupName())
+    return
+  }
+  var desiredHosts, desiredPaths []string
+  if c.rc.HostHeader != "" {
+    desiredHosts = append(desiredHosts, c.rc.HostHeader)
+  }
+  if c.rc.PathPattern != "" {
+    desiredPaths = append(desiredPaths, c.rc.PathPattern)
+  }
+  d.compare(resourceListenerRule, resource, "host header", joinOrNone(desiredHosts), joinOrNone(getRuleConditionValues(&listenerRule.Rule, "host-header")))
+  d.compare(resourceListenerRule, resource, "path pattern", joinOrNone(desiredPaths), joinOrNone(getRuleConditionValues(&listenerRule.Rule, "path-pattern")))
+}
+
+func (s *Stack) findLifecycleHook(name string) *autoscalingtypes.LifecycleHook {
+  for i := range s.LifecycleHooks {
+    if aws.ToString(s.LifecycleHooks[i].LifecycleHookName) == name {
+      return &s.LifecycleHooks[i]
+    }
+  }
+  return nil
+}
+
+func formatLifecycleHook(transition string, heartbeatTimeout int32, defaultResult string, notificationTargetARN string) string {
+  description := fmt.Sprintf("%s, heartbeat %ds, default %s", transition, heartbeatTimeout, defaultResult)
+  if notificationTargetARN != "" {
+    description += ", notifying " + notificationTargetARN
+  }
+  return description
+}
+
+func (c *Client) diffLifecycleHooks(d *driftCollector, stack *Stack) {
+  for _, transition := range []LifecycleTransition{LifecycleTransitionLaunch, LifecycleTransitionTermination} {
+    hookName := c.rc.GetLifecycleHookName(transition)
+    desired := "none"
+    if hook := c.rc.getLifecycleHook(transition); hook != nil {
+      desired = formatLifecycleHook(transition.getAWSName(), int32(hook.HeartbeatTimeout.Seconds()), hook.DefaultResult, hook.NotificationTargetARN)
+    }
+    actual := "none"
+    if hook := stack.findLifecycleHook(hookName); hook != nil {
+      actual = formatLifecycleHook(aws.ToString(hook.LifecycleTransition), aws.ToInt32(hook.HeartbeatTimeout), aws.ToString(hook.DefaultResult), aws.ToString(hook.NotificationTargetARN))
+    }
+    d.compare(resourceLifecycleHook, fmt.Sprintf("lifecycle hook %q", hookName), "settings", desired, actual)
+  }
+}
+
+func formatWarmPool(poolState autoscalingtypes.WarmPoolState, minSize int32, maxPreparedCapacity int32, reuseOnScaleIn bool) string {
+  maxPrepared := "the group max size"
+  if maxPreparedCapacity >= 0 {
+    maxPrepared = fmt.Sprint(maxPreparedCapacity)
+  }
+  description := fmt.Sprintf("%s, min size %d, max prepared capacity %s", poolState, minSize, maxPrepared)
+  if reuseOnScaleIn {
+    description += ", reused on scale in"
+  }
+  return description
+}
+
+func (c *Client) diffWarmPool(d *driftCollector, stack *Stack) {
+  desired := "none"
+  if warmPool := c.rc.WarmPool; warmPool != nil {
+    desired = formatWarmPool(warmPool.PoolState, warmPool.MinSize, warmPool.MaxPreparedCapacity, warmPool.ReuseOnScaleIn)
+  }
+  actual := "none"
+  if config := stack.Group.WarmPoolConfiguration; config != nil && config.Status != autoscalingtypes.WarmPoolStatusPendingDelete {
+    maxPreparedCapacity := int32(defaultWarmPoolMaxPreparedCapacity)
+    if config.MaxGroupPreparedCapacity != nil {
+      maxPreparedCapacity = *config.MaxGroupPreparedCapacity
+    }
+    reuseOnScaleIn := config.InstanceReusePolicy != nil && aws.ToBool(config.InstanceReusePolicy.ReuseOnScaleIn)
+    actual = formatWarmPool(config.PoolState, aws.ToInt32(config.MinSize), maxPreparedCapacity, reuseOnScaleIn)
+  }
+  d.compare(resourceWarmPool, fmt.Sprintf("warm pool of the group %q", c.rc.GetGroupName()), "settings", desired, actual)
+}
+
+func (c *Client) diffNotifications(d *driftCollector, stack *Stack) {
+  if !c.rc.HasNotifications() {
+    return
+  }
+  desired := append([]string(nil), c.rc.NotificationTypes...)
+  d.compare(resourceNotifications, fmt.Sprintf("notifications to %s", c.rc.NotificationTopicARN), "notification types", joinOrNone(desired), joinOrNone(stack.getNotificationTypes(c.rc.NotificationTopicARN)))
+}
+
+func (c *Client) diffStack(stack *Stack) []*Drift {
+  d := &driftCollector{}
+  c.diffAutoScalingGroup(d, stack)
+  c.diffLaunchTemplate(d, stack)
+  if targetGroup := stack.findTargetGroup(c.rc.GetTargetGroupName()); targetGroup != nil {
+    c.diffTargetGroup(d, targetGroup)
+  }
+  if c.rc.UsesExistingBalancer() {
+    c.diffListenerRule(d, stack)
+  } else {
+    c.diffListener(d, stack)
+  }
+  c.diffLifecycleHooks(d, stack)
+  c.diffWarmPool(d, stack)
+  c.diffNotifications(d, stack)
+  return d.drifts
+}
+
+func (c *Client) makeMissingGroupDrift() *Drift {
+  return &Drift{
+    ResourceType: resourceAutoScalingGroup,
+    Resource:     fmt.Sprintf("auto scaling group %q", c.rc.GetGroupName()),
+    Field:        "exists",
+    Desired:      "true",
+    Actual:       "false",
+  }
+}
+
+func (c *Client) Diff(ctx context.Context) ([]*Drift, error) {
+  group, err := c.findAutoScalingGroup(ctx, c.rc.GetGroupName())
+  if err != nil {
+    return nil, err
+  }
+  if group == nil {
+    return []*Drift{c.makeMissingGroupDrift()}, nil
+  }
+  stack, err := c.describeGroupStack(ctx, group)
+  if err != nil {
+    return nil, err
+  }
+  return c.diffStack(stack), nil
+}
+
+func WriteDrifts(out io.Writer, drifts []*Drift, format OutputFormat) error {
+  switch format {
+  case OutputFormatTable:
+    for _, drift := range drifts {
+      if _, err := fmt.Fprintf(out, "%s: %s: desired %s, actual %s\n", drift.Resource, drift.Field, drift.Desired, drift.Actual); err != nil {
+        return err
+      }
+    }
+    return nil
+  case OutputFormatJSON:
+    if drifts == nil {
+      drifts = []*Drift{}
+    }
+    encoder := json.NewEncoder(out)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(drifts)
+  }
+  return fmt.Errorf("unknown output format %q, expected %q or %q", format, OutputFormatTable, OutputFormatJSON)
+}
+
+func (b *Builder) Diff(ctx context.Context, spec *RunConfig) ([]*Drift, error) {
+  if err := spec.ValidateArtifactNames(); err != nil {
+    return nil, err
+  }
+  c, err := b.newClient(ctx, spec)
+  if err != nil {
+    return nil, err
+  }
+  return c.Diff(ctx)
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+  "log"
+  "sync"
+  "time"
+)
+
+type EventKind string
+
+const (
+  EventKindStepStarted    EventKind = "step_started"
+  EventKindStepFinished   EventKind = "step_finished"
+  EventKindStepFailed     EventKind = "step_failed"
+  EventKindResourceState  EventKind = "resource_state"
+  EventKindInstanceHealth EventKind = "instance_health"
+  EventKindCleanup        EventKind = "cleanup"
+)
+
+type Event struct {
+  Kind           EventKind `json:"kind,omitempty"`
+  Time           time.Time `json:"time"`
+  ElapsedSeconds float64   `json:"elapsed_seconds,omitempty"`
+  Step           string    `json:"step,omitempty"`
+  ResourceType   string    `json:"resource_type,omitempty"`
+  ResourceID     string    `json:"resource_id,omitempty"`
+  PreviousState  string    `json:"previous_state,omitempty"`
+  State          string    `json:"state,omitempty"`
+  Message        string    `json:"message"`
+}
+
+type EventSink interface {
+  HandleEvent(event Event)
+}
+
+type EventSinkFunc func(event Event)
+
+func (f EventSinkFunc) HandleEvent(event Event) {
+  f(event)
+}
+
+type ChannelEventSink chan<- Event
+
+func (s ChannelEventSink) HandleEvent(event Event) {
+  select {
+  case s <- event:
+  default:
+    log.Printf("the event channel is full, dropping the event: %s", event.Message)
+  }
+}
+
+type eventDispatcher struct {
+  mutex          sync.Mutex
+  sinks          []EventSink
+  resourceStates map[string]string
+}
+
+func (d *eventDispatcher) addSink(sink EventSink) {
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+  d.sinks = append(d.sinks, sink)
+}
+
+func (d *eventDispatcher) dispatch(event *Event) {
+  sinks, ok := d.track(event)
+  if !ok {
+    return
+  }
+  for _, sink := range sinks {
+    sink.HandleEvent(*event)
+  }
+}
+
+func (d *eventDispatcher) track(event *Event) ([]EventSink, bool) {
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+  if event.Kind == EventKindResourceState || event.Kind == EventKindInstanceHealth {
+    if d.resourceStates == nil {
+      d.resourceStates = map[string]string{}
+    }
+    key := event.ResourceType + "/" + event.ResourceID
+    previousState, seen := d.resourceStates[key]
+    if seen && previousState == event.State {
+      return nil, false
+    }
+    d.resourceStates[key] = event.State
+    event.PreviousState = previousState
+  }
+  return append([]EventSink(nil), d.sinks...), true
+}
+
+func (c *Client) AddEventSink(sink EventSink) {
+  c.events.addSink(sink)
+}
+
+func (c *Client) emit(event *Event) {
+  event.Time = time.Now()
+  event.ElapsedSeconds = event.Time.Sub(c.startTime).Seconds()
+  c.events.dispatch(event)
+  logEvent(event)
+}
@@ -0,0 +1,119 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync"
+  "time"
+)
+
+var errStepSkipped = errors.New("skipped because another build step failed")
+
+type buildStep struct {
+  name      string
+  dependsOn []string
+  run       func(ctx context.Context) error
+
+  done       chan struct{}
+  startTime  time.Time
+  finishTime time.Time
+  err        error
+}
+
+func (s *buildStep) getStatus() string {
+  switch {
+  case s.err == errStepSkipped:
+    return "skipped"
+  case s.err != nil:
+    return "failed"
+  case s.finishTime.IsZero():
+    return "pending"
+  }
+  return "finished"
+}
+
+func runBuildSteps(ctx context.Context, steps []*buildStep, emit func(event *Event)) error {
+  stepsByName := map[string]*buildStep{}
+  for _, step := range steps {
+    if _, ok := stepsByName[step.name]; ok {
+      return fmt.Errorf("the build step %q is declared twice", step.name)
+    }
+    for _, dependency := range step.dependsOn {
+      if _, ok := stepsByName[dependency]; !ok {
+        return fmt.Errorf("the build step %q depends on %q, which is not declared before it", step.name, dependency)
+      }
+    }
+    step.done = make(chan struct{})
+    stepsByName[step.name] = step
+  }
+
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+  var firstErr error
+  var firstErrMutex sync.Mutex
+  fail := func(err error) bool {
+    firstErrMutex.Lock()
+    defer firstErrMutex.Unlock()
+    if firstErr != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+      return false
+    }
+    if firstErr == nil {
+      firstErr = err
+      cancel()
+    }
+    return true
+  }
+  var wg sync.WaitGroup
+  for _, step := range steps {
+    wg.Add(1)
+    go func(step *buildStep) {
+      defer wg.Done()
+      defer close(step.done)
+      for _, dependency := range step.dependsOn {
+        <-stepsByName[dependency].done
+        if stepsByName[dependency].err != nil {
+          step.err = errStepSkipped
+          return
+        }
+      }
+      step.startTime = time.Now()
+      emit(&Event{
+        Kind:    EventKindStepStarted,
+        Step:    step.name,
+        State:   "started",
+        Message: fmt.Sprintf("step %q started", step.name),
+      })
+      step.err = step.run(ctx)
+      step.finishTime = time.Now()
+      duration := step.finishTime.Sub(step.startTime).Round(time.Second)
+      if step.err != nil {
+        if !fail(step.err) {
+          step.err = errStepSkipped
+          emit(&Event{
+            Kind:    EventKindStepFailed,
+            Step:    step.name,
+            State:   "skipped",
+            Message: fmt.Sprintf("step %q was cancelled after %v because another step failed", step.name, duration),
+          })
+          return
+        }
+        emit(&Event{
+          Kind:    EventKindStepFailed,
+          Step:    step.name,
+          State:   "failed",
+          Message: fmt.Sprintf("step %q failed after %v", step.name, duration),
+        })
+        return
+      }
+      emit(&Event{
+        Kind:    EventKindStepFinished,
+        Step:    step.name,
+        State:   "finished",
+        Message: fmt.Sprintf("step %q finished in %v", step.name, duration),
+      })
+    }(step)
+  }
+  wg.Wait()
+  return firstErr
+}
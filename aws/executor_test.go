@@ -0,0 +1,194 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+var errTestStep = errors.New("test step failed")
+
+type stepRecorder struct {
+  mutex  sync.Mutex
+  events []string
+}
+
+func (r *stepRecorder) record(event string) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  r.events = append(r.events, event)
+}
+
+func (r *stepRecorder) indexOf(event string) int {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  for i, recorded := range r.events {
+    if recorded == event {
+      return i
+    }
+  }
+  return -1
+}
+
+func (r *stepRecorder) newStep(name string, run func(ctx context.Context) error, dependsOn ...string) *buildStep {
+  return &buildStep{
+    name:      name,
+    dependsOn: dependsOn,
+    run: func(ctx context.Context) error {
+      r.record("start " + name)
+      err := run(ctx)
+      r.record("finish " + name)
+      return err
+    },
+  }
+}
+
+func succeed(ctx context.Context) error {
+  return nil
+}
+
+func failAfter(delay time.Duration) func(ctx context.Context) error {
+  return func(ctx context.Context) error {
+    time.Sleep(delay)
+    return errTestStep
+  }
+}
+
+func waitForCancel(ctx context.Context) error {
+  select {
+  case <-ctx.Done():
+    return ctx.Err()
+  case <-time.After(5 * time.Second):
+    return nil
+  }
+}
+
+func TestRunBuildSteps(t *testing.T) {
+  for _, test := range []struct {
+    name         string
+    makeSteps    func(r *stepRecorder) []*buildStep
+    wantErr      error
+    wantStatuses map[string]string
+    wantOrder    [][2]string
+  }{
+    {
+      name: "dependencies run in order",
+      makeSteps: func(r *stepRecorder) []*buildStep {
+        return []*buildStep{
+          r.newStep("ami", succeed),
+          r.newStep("target group", succeed),
+          r.newStep("launch template", succeed, "ami"),
+          r.newStep("group", succeed, "launch template", "target group"),
+        }
+      },
+      wantStatuses: map[string]string{"ami": "finished", "target group": "finished", "launch template": "finished", "group": "finished"},
+      wantOrder: [][2]string{
+        {"finish ami", "start launch template"},
+        {"finish launch template", "start group"},
+        {"finish target group", "start group"},
+      },
+    },
+    {
+      name: "a failed step skips its dependents",
+      makeSteps: func(r *stepRecorder) []*buildStep {
+        return []*buildStep{
+          r.newStep("ami", failAfter(0)),
+          r.newStep("launch template", succeed, "ami"),
+          r.newStep("group", succeed, "launch template"),
+        }
+      },
+      wantErr:      errTestStep,
+      wantStatuses: map[string]string{"ami": "failed", "launch template": "skipped", "group": "skipped"},
+    },
+    {
+      name: "a failed step cancels its siblings",
+      makeSteps: func(r *stepRecorder) []*buildStep {
+        return []*buildStep{
+          r.newStep("ami", waitForCancel),
+          r.newStep("target group", failAfter(10*time.Millisecond)),
+          r.newStep("group", succeed, "ami", "target group"),
+        }
+      },
+      wantErr:      errTestStep,
+      wantStatuses: map[string]string{"ami": "skipped", "target group": "failed", "group": "skipped"},
+    },
+    {
+      name: "a sibling failing on its own is reported as failed",
+      makeSteps: func(r *stepRecorder) []*buildStep {
+        return []*buildStep{
+          r.newStep("ami", func(ctx context.Context) error {
+            <-ctx.Done()
+            return errors.New("cannot create the AMI")
+          }),
+          r.newStep("target group", failAfter(10*time.Millisecond)),
+        }
+      },
+      wantErr:      errTestStep,
+      wantStatuses: map[string]string{"ami": "failed", "target group": "failed"},
+    },
+  } {
+    t.Run(test.name, func(t *testing.T) {
+      recorder := &stepRecorder{}
+      steps := test.makeSteps(recorder)
+      var events []*Event
+      var eventsMutex sync.Mutex
+      err := runBuildSteps(context.Background(), steps, func(event *Event) {
+        eventsMutex.Lock()
+        defer eventsMutex.Unlock()
+        events = append(events, event)
+      })
+      if !errors.Is(err, test.wantErr) {
+        t.Fatalf("expected the error %v, got %v", test.wantErr, err)
+      }
+      for _, step := range steps {
+        if status := step.getStatus(); status != test.wantStatuses[step.name] {
+          t.Errorf("expected the step %q to be %s, got %s", step.name, test.wantStatuses[step.name], status)
+        }
+      }
+      for _, order := range test.wantOrder {
+        before, after := recorder.indexOf(order[0]), recorder.indexOf(order[1])
+        if before == -1 || after == -1 || before > after {
+          t.Errorf("expected %q before %q, got %v", order[0], order[1], recorder.events)
+        }
+      }
+      for _, step := range steps {
+        if step.getStatus() == "skipped" && step.startTime.IsZero() {
+          for _, event := range events {
+            if event.Step == step.name {
+              t.Errorf("expected no events of the step %q, which never started, got %q", step.name, event.Message)
+            }
+          }
+        }
+      }
+    })
+  }
+}
+
+func TestRunBuildStepsRejectsBadDeclarations(t *testing.T) {
+  for _, test := range []struct {
+    name    string
+    steps   []*buildStep
+    wantErr string
+  }{
+    {
+      name:    "duplicate step",
+      steps:   []*buildStep{{name: "ami", run: succeed}, {name: "ami", run: succeed}},
+      wantErr: `the build step "ami" is declared twice`,
+    },
+    {
+      name:    "dependency declared later",
+      steps:   []*buildStep{{name: "launch template", dependsOn: []string{"ami"}, run: succeed}, {name: "ami", run: succeed}},
+      wantErr: `the build step "launch template" depends on "ami", which is not declared before it`,
+    },
+  } {
+    t.Run(test.name, func(t *testing.T) {
+      err := runBuildSteps(context.Background(), test.steps, func(event *Event) {})
+      if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+        t.Fatalf("expected the error %q, got %v", test.wantErr, err)
+      }
+    })
+  }
+}
@@ -0,0 +1,27 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+)
+
+type ExportFormat string
+
+const (
+  ExportFormatTerraform ExportFormat = "terraform"
+)
+
+func (b *Builder) Export(ctx context.Context, groupName string, format ExportFormat) (string, error) {
+  if format != ExportFormatTerraform {
+    return "", fmt.Errorf("unknown export format %q, expected %q", format, ExportFormatTerraform)
+  }
+  c, err := b.newClient(ctx, &RunConfig{GroupName: groupName})
+  if err != nil {
+    return "", err
+  }
+  stack, err := c.DescribeStack(ctx, groupName)
+  if err != nil {
+    return "", err
+  }
+  return ExportTerraform(stack), nil
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "strings"
+  "time"
+)
+
+const (
+  defaultTerminationPolicy = "Default"
+  minMaxInstanceLifetime   = 24 * time.Hour
+  maxMaxInstanceLifetime   = 365 * 24 * time.Hour
+  DefaultGroupCooldown     = 300 * time.Second
+
+  maxInstanceProtectionBatch = 50
+)
+
+var terminationPolicies = []string{
+  defaultTerminationPolicy,
+  "AllocationStrategy",
+  "OldestLaunchTemplate",
+  "OldestLaunchConfiguration",
+  "ClosestToNextInstanceHour",
+  "NewestInstance",
+  "OldestInstance",
+}
+
+func isKnownTerminationPolicy(policy string) bool {
+  for _, knownPolicy := range terminationPolicies {
+    if policy == knownPolicy {
+      return true
+    }
+  }
+  return false
+}
+
+func (c *RunConfig) validateGroupSettings() error {
+  for _, policy := range c.TerminationPolicies {
+    if !isKnownTerminationPolicy(policy) {
+      return fmt.Errorf("unknown termination policy %q, expected one of %s", policy, strings.Join(terminationPolicies, ", "))
+    }
+  }
+  if c.MaxInstanceLifetime != 0 && (c.MaxInstanceLifetime < minMaxInstanceLifetime || c.MaxInstanceLifetime > maxMaxInstanceLifetime) {
+    return fmt.Errorf("the max instance lifetime %v is out of the %v-%v range", c.MaxInstanceLifetime, minMaxInstanceLifetime, maxMaxInstanceLifetime)
+  }
+  if c.DefaultCooldown < 0 {
+    return fmt.Errorf("the default cooldown must not be negative, got %v", c.DefaultCooldown)
+  }
+  return nil
+}
+
+func (c *RunConfig) getTerminationPolicies() []string {
+  if len(c.TerminationPolicies) == 0 {
+    return []string{defaultTerminationPolicy}
+  }
+  return c.TerminationPolicies
+}
+
+func (c *RunConfig) getMaxInstanceLifetime() *int32 {
+  if c.MaxInstanceLifetime == 0 {
+    return nil
+  }
+  return aws.Int32(int32(c.MaxInstanceLifetime.Seconds()))
+}
+
+func (c *RunConfig) getDefaultCooldown() int32 {
+  if c.DefaultCooldown == 0 {
+    return int32(DefaultGroupCooldown.Seconds())
+  }
+  return int32(c.DefaultCooldown.Seconds())
+}
+
+func formatMaxInstanceLifetime(seconds int32) string {
+  if seconds == 0 {
+    return "none"
+  }
+  return (time.Duration(seconds) * time.Second).String()
+}
@@ -0,0 +1,240 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "io"
+  "strings"
+  "time"
+)
+
+const (
+  minLifecycleHookHeartbeatTimeout = 30 * time.Second
+  maxLifecycleHookHeartbeatTimeout = 2 * time.Hour
+)
+
+type LifecycleTransition string
+
+const (
+  LifecycleTransitionLaunch      LifecycleTransition = "launch"
+  LifecycleTransitionTermination LifecycleTransition = "termination"
+)
+
+func (t LifecycleTransition) getAWSName() string {
+  if t == LifecycleTransitionTermination {
+    return "autoscaling:EC2_INSTANCE_TERMINATING"
+  }
+  return "autoscaling:EC2_INSTANCE_LAUNCHING"
+}
+
+const (
+  LifecycleResultContinue = "CONTINUE"
+  LifecycleResultAbandon  = "ABANDON"
+)
+
+type LifecycleHook struct {
+  Transition            LifecycleTransition
+  HeartbeatTimeout      time.Duration
+  DefaultResult         string
+  NotificationTargetARN string
+  RoleARN               string
+}
+
+func parseLifecycleResult(result string) (string, error) {
+  result = strings.ToUpper(result)
+  if result != LifecycleResultContinue && result != LifecycleResultAbandon {
+    return "", fmt.Errorf("unknown lifecycle action result %q, expected %q or %q", result, LifecycleResultContinue, LifecycleResultAbandon)
+  }
+  return result, nil
+}
+
+func ParseLifecycleHook(transition LifecycleTransition, description string) (*LifecycleHook, error) {
+  parts := strings.SplitN(description, ",", 4)
+  heartbeatTimeout, err := time.ParseDuration(parts[0])
+  if err != nil {
+    return nil, fmt.Errorf("cannot parse the heartbeat timeout %q of the %s hook: %w", parts[0], transition, err)
+  }
+  if heartbeatTimeout < minLifecycleHookHeartbeatTimeout || heartbeatTimeout > maxLifecycleHookHeartbeatTimeout {
+    return nil, fmt.Errorf("the heartbeat timeout %v of the %s hook is out of the %v-%v range", heartbeatTimeout, transition, minLifecycleHookHeartbeatTimeout, maxLifecycleHookHeartbeatTimeout)
+  }
+  hook := &LifecycleHook{
+    Transition:       transition,
+    HeartbeatTimeout: heartbeatTimeout,
+    DefaultResult:    LifecycleResultAbandon,
+  }
+  if len(parts) > 1 && parts[1] != "" {
+    if hook.DefaultResult, err = parseLifecycleResult(parts[1]); err != nil {
+      return nil, err
+    }
+  }
+  if len(parts) > 2 {
+    hook.NotificationTargetARN = parts[2]
+  }
+  if len(parts) > 3 {
+    hook.RoleARN = parts[3]
+  }
+  if (hook.NotificationTargetARN == "") != (hook.RoleARN == "") {
+    return nil, fmt.Errorf("the notification target and the role of the %s hook must be set up together", transition)
+  }
+  return hook, nil
+}
+
+func (c *RunConfig) GetLifecycleHookName(transition LifecycleTransition) string {
+  return c.GetGroupName() + "-" + string(transition)
+}
+
+func (c *RunConfig) getLifecycleHook(transition LifecycleTransition) *LifecycleHook {
+  for _, hook := range c.LifecycleHooks {
+    if hook.Transition == transition {
+      return hook
+    }
+  }
+  return nil
+}
+
+func optionalString(value string) *string {
+  if value == "" {
+    return nil
+  }
+  return aws.String(value)
+}
+
+func (c *Client) makeLifecycleHookSpecifications() []types.LifecycleHookSpecification {
+  var specifications []types.LifecycleHookSpecification
+  for _, hook := range c.rc.LifecycleHooks {
+    specifications = append(specifications, types.LifecycleHookSpecification{
+      LifecycleHookName:     aws.String(c.rc.GetLifecycleHookName(hook.Transition)),
+      LifecycleTransition:   aws.String(hook.Transition.getAWSName()),
+      DefaultResult:         aws.String(hook.DefaultResult),
+      HeartbeatTimeout:      aws.Int32(int32(hook.HeartbeatTimeout.Seconds())),
+      NotificationTargetARN: optionalString(hook.NotificationTargetARN),
+      RoleARN:               optionalString(hook.RoleARN),
+    })
+  }
+  return specifications
+}
+
+func (c *Client) describeLifecycleHooks(ctx context.Context, groupName string) ([]types.LifecycleHook, error) {
+  res, err := c.autoscalingClient.DescribeLifecycleHooks(ctx, &autoscaling.DescribeLifecycleHooksInput{
+    AutoScalingGroupName: aws.String(groupName),
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the lifecycle hooks of the group %q: %w", groupName, err)
+  }
+  return res.LifecycleHooks, nil
+}
+
+func (c *Client) putLifecycleHook(ctx context.Context, hook *LifecycleHook) error {
+  hookName := c.rc.GetLifecycleHookName(hook.Transition)
+  err := c.withRetries(ctx, "put a lifecycle hook", func() error {
+    _, err := c.autoscalingClient.PutLifecycleHook(ctx, &autoscaling.PutLifecycleHookInput{
+      AutoScalingGroupName:  aws.String(c.rc.GetGroupName()),
+      LifecycleHookName:     aws.String(hookName),
+      LifecycleTransition:   aws.String(hook.Transition.getAWSName()),
+      DefaultResult:         aws.String(hook.DefaultResult),
+      HeartbeatTimeout:      aws.Int32(int32(hook.HeartbeatTimeout.Seconds())),
+      NotificationTargetARN: optionalString(hook.NotificationTargetARN),
+      RoleARN:               optionalString(hook.RoleARN),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot put the lifecycle hook %q: %w", hookName, err)
+  }
+  c.emitUpdate(resourceLifecycleHook, hookName, "updated", fmt.Sprintf("updated the lifecycle hook %q", hookName))
+  return nil
+}
+
+func (c *Client) deleteLifecycleHook(ctx context.Context, transition LifecycleTransition) error {
+  hookName := c.rc.GetLifecycleHookName(transition)
+  err := c.withRetries(ctx, "delete a lifecycle hook", func() error {
+    _, err := c.autoscalingClient.DeleteLifecycleHook(ctx, &autoscaling.DeleteLifecycleHookInput{
+      AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
+      LifecycleHookName:    aws.String(hookName),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot delete the lifecycle hook %q: %w", hookName, err)
+  }
+  c.emitUpdate(resourceLifecycleHook, hookName, "deleted", fmt.Sprintf("deleted the lifecycle hook %q", hookName))
+  return nil
+}
+
+type LifecycleAction struct {
+  GroupName  string
+  HookName   string
+  InstanceID string
+  Token      string
+  Result     string
+  Heartbeat  bool
+}
+
+func (c *Client) CompleteLifecycleAction(ctx context.Context, action *LifecycleAction) error {
+  if action.Heartbeat {
+    _, err := c.autoscalingClient.RecordLifecycleActionHeartbeat(ctx, &autoscaling.RecordLifecycleActionHeartbeatInput{
+      AutoScalingGroupName: aws.String(action.GroupName),
+      LifecycleHookName:    aws.String(action.HookName),
+      InstanceId:           optionalString(action.InstanceID),
+      LifecycleActionToken: optionalString(action.Token),
+    })
+    if err != nil {
+      return fmt.Errorf("cannot record the heartbeat of the lifecycle hook %q: %w", action.HookName, err)
+    }
+    return nil
+  }
+  result, err := parseLifecycleResult(action.Result)
+  if err != nil {
+    return err
+  }
+  _, err = c.autoscalingClient.CompleteLifecycleAction(ctx, &autoscaling.CompleteLifecycleActionInput{
+    AutoScalingGroupName:  aws.String(action.GroupName),
+    LifecycleHookName:     aws.String(action.HookName),
+    LifecycleActionResult: aws.String(result),
+    InstanceId:            optionalString(action.InstanceID),
+    LifecycleActionToken:  optionalString(action.Token),
+  })
+  if err != nil {
+    return fmt.Errorf("cannot complete the lifecycle action of the hook %q: %w", action.HookName, err)
+  }
+  return nil
+}
+
+func getInstanceMetadata(ctx context.Context, client *imds.Client, path string) (string, error) {
+  res, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+  if err != nil {
+    return "", fmt.Errorf("cannot get the %s from the instance metadata: %w", path, err)
+  }
+  defer res.Content.Close()
+  content, err := io.ReadAll(res.Content)
+  if err != nil {
+    return "", fmt.Errorf("cannot read the %s from the instance metadata: %w", path, err)
+  }
+  return string(content), nil
+}
+
+func (b *Builder) CompleteLifecycleAction(ctx context.Context, action LifecycleAction) error {
+  awsConfig, err := b.getAWSConfig(ctx)
+  if err != nil {
+    return err
+  }
+  metadataClient := imds.NewFromConfig(awsConfig)
+  if action.InstanceID == "" && action.Token == "" {
+    if action.InstanceID, err = getInstanceMetadata(ctx, metadataClient, "instance-id"); err != nil {
+      return err
+    }
+  }
+  if awsConfig.Region == "" {
+    res, err := metadataClient.GetRegion(ctx, &imds.GetRegionInput{})
+    if err != nil {
+      return fmt.Errorf("cannot get the region from the instance metadata: %w", err)
+    }
+    awsConfig.Region = res.Region
+  }
+  c := NewClient(awsConfig, &RunConfig{GroupName: action.GroupName})
+  return c.CompleteLifecycleAction(ctx, &action)
+}
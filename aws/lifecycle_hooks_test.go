@@ -0,0 +1,55 @@
+package aws
+
+import (
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestParseLifecycleHook(t *testing.T) {
+  for _, test := range []struct {
+    description string
+    want        *LifecycleHook
+    wantErr     string
+  }{
+    {
+      description: "10m",
+      want:        &LifecycleHook{Transition: LifecycleTransitionLaunch, HeartbeatTimeout: 10 * time.Minute, DefaultResult: LifecycleResultAbandon},
+    },
+    {
+      description: "30s,continue",
+      want:        &LifecycleHook{Transition: LifecycleTransitionLaunch, HeartbeatTimeout: 30 * time.Second, DefaultResult: LifecycleResultContinue},
+    },
+    {
+      description: "2h,,arn:aws:sns:us-east-1:123456789012:hooks,arn:aws:iam::123456789012:role/hooks",
+      want: &LifecycleHook{
+        Transition:            LifecycleTransitionLaunch,
+        HeartbeatTimeout:      2 * time.Hour,
+        DefaultResult:         LifecycleResultAbandon,
+        NotificationTargetARN: "arn:aws:sns:us-east-1:123456789012:hooks",
+        RoleARN:               "arn:aws:iam::123456789012:role/hooks",
+      },
+    },
+    {description: "ten minutes", wantErr: `cannot parse the heartbeat timeout "ten minutes" of the launch hook`},
+    {description: "10s", wantErr: "the heartbeat timeout 10s of the launch hook is out of the 30s-2h0m0s range"},
+    {description: "3h", wantErr: "the heartbeat timeout 3h0m0s of the launch hook is out of the 30s-2h0m0s range"},
+    {description: "10m,RETRY", wantErr: "RETRY"},
+    {description: "10m,ABANDON,arn:aws:sns:us-east-1:123456789012:hooks", wantErr: "the notification target and the role of the launch hook must be set up together"},
+  } {
+    hook, err := ParseLifecycleHook(LifecycleTransitionLaunch, test.description)
+    if test.wantErr != "" {
+      if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+        t.Errorf("%q: expected the error %q, got %v", test.description, test.wantErr, err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%q: unexpected error %v", test.description, err)
+      continue
+    }
+    if !reflect.DeepEqual(hook, test.want) {
+      t.Errorf("%q: expected the hook %+v, got %+v", test.description, test.want, hook)
+    }
+  }
+}
@@ -0,0 +1,307 @@
+package aws
+
+import (
+  "context"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+)
+
+type GroupSummary struct {
+  Region                string     `json:"region"`
+  Name                  string     `json:"name"`
+  MinSize               int32      `json:"min_size"`
+  MaxSize               int32      `json:"max_size"`
+  DesiredCapacity       int32      `json:"desired_capacity"`
+  InstancesCount        int        `json:"instances_count"`
+  HealthyCount          int        `json:"healthy_count"`
+  LaunchTemplateVersion int64      `json:"launch_template_version,omitempty"`
+  AMIID                 string     `json:"ami_id,omitempty"`
+  AMIName               string     `json:"ami_name,omitempty"`
+  LoadBalancerDNSNames  []string   `json:"load_balancer_dns_names"`
+  CreatedTime           *time.Time `json:"created_time,omitempty"`
+  Untagged              bool       `json:"untagged,omitempty"`
+}
+
+type ListOptions struct {
+  Regions []string
+  Tags    map[string]string
+}
+
+func ParseTagFilter(value string) (string, string) {
+  parts := strings.SplitN(value, "=", 2)
+  if len(parts) == 1 {
+    return parts[0], ""
+  }
+  return parts[0], parts[1]
+}
+
+func hasBuilderTag(group *autoscalingtypes.AutoScalingGroup) bool {
+  for _, tag := range group.Tags {
+    if aws.ToString(tag.Key) == BuilderTagKey {
+      return true
+    }
+  }
+  return false
+}
+
+func isBuilderGroup(group *autoscalingtypes.AutoScalingGroup) bool {
+  if hasBuilderTag(group) {
+    return true
+  }
+  launchTemplate := getGroupLaunchTemplate(group)
+  return launchTemplate != nil && aws.ToString(launchTemplate.LaunchTemplateName) == aws.ToString(group.AutoScalingGroupName) && len(group.TargetGroupARNs) != 0
+}
+
+func makeTagFilters(tags map[string]string) []autoscalingtypes.Filter {
+  var filters []autoscalingtypes.Filter
+  for key, value := range tags {
+    if value == "" {
+      filters = append(filters, autoscalingtypes.Filter{
+        Name:   aws.String("tag-key"),
+        Values: []string{key},
+      })
+      continue
+    }
+    filters = append(filters, autoscalingtypes.Filter{
+      Name:   aws.String("tag:" + key),
+      Values: []string{value},
+    })
+  }
+  return filters
+}
+
+func (c *Client) findBuilderGroups(ctx context.Context, tags map[string]string) ([]autoscalingtypes.AutoScalingGroup, error) {
+  var groups []autoscalingtypes.AutoScalingGroup
+  var nextToken *string
+  for {
+    res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+      Filters:   makeTagFilters(tags),
+      NextToken: nextToken,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the auto scaling groups in %s: %w", c.region, err)
+    }
+    for _, group := range res.AutoScalingGroups {
+      if isBuilderGroup(&group) {
+        groups = append(groups, group)
+      }
+    }
+    nextToken = res.NextToken
+    if nextToken == nil {
+      break
+    }
+  }
+  return groups, nil
+}
+
+func (c *Client) getImageNames(ctx context.Context, imageIDs []string) (map[string]string, error) {
+  names := map[string]string{}
+  var uniqueIDs []string
+  seen := map[string]bool{}
+  for _, imageID := range imageIDs {
+    if !seen[imageID] {
+      seen[imageID] = true
+      uniqueIDs = append(uniqueIDs, imageID)
+    }
+  }
+  if len(uniqueIDs) == 0 {
+    return names, nil
+  }
+  res, err := c.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+    Filters: []ec2types.Filter{
+      {
+        Name:   aws.String("image-id"),
+        Values: uniqueIDs,
+      },
+    },
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the images %s: %w", strings.Join(uniqueIDs, ", "), err)
+  }
+  for _, image := range res.Images {
+    names[*image.ImageId] = aws.ToString(image.Name)
+  }
+  return names, nil
+}
+
+func (c *Client) getLoadBalancerDNSNames(ctx context.Context, targetGroupARNs []string) ([]string, error) {
+  if len(targetGroupARNs) == 0 {
+    return nil, nil
+  }
+  targetGroupsRes, err := c.elbClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+    TargetGroupArns: targetGroupARNs,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the target groups: %w", err)
+  }
+  var loadBalancerARNs []string
+  for _, targetGroup := range targetGroupsRes.TargetGroups {
+    loadBalancerARNs = append(loadBalancerARNs, targetGroup.LoadBalancerArns...)
+  }
+  if len(loadBalancerARNs) == 0 {
+    return nil, nil
+  }
+  loadBalancersRes, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+    LoadBalancerArns: loadBalancerARNs,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the load balancers: %w", err)
+  }
+  var dnsNames []string
+  for _, loadBalancer := range loadBalancersRes.LoadBalancers {
+    dnsNames = append(dnsNames, aws.ToString(loadBalancer.DNSName))
+  }
+  return dnsNames, nil
+}
+
+func (c *Client) ListGroups(ctx context.Context, tags map[string]string) ([]*GroupSummary, error) {
+  groups, err := c.findBuilderGroups(ctx, tags)
+  if err != nil {
+    return nil, err
+  }
+  var summaries []*GroupSummary
+  var imageIDs []string
+  for i := range groups {
+    group := &groups[i]
+    summary := &GroupSummary{
+      Region:          c.region,
+      Name:            *group.AutoScalingGroupName,
+      MinSize:         aws.ToInt32(group.MinSize),
+      MaxSize:         aws.ToInt32(group.MaxSize),
+      DesiredCapacity: aws.ToInt32(group.DesiredCapacity),
+      InstancesCount:  len(group.Instances),
+      CreatedTime:     group.CreatedTime,
+      Untagged:        !hasBuilderTag(group),
+    }
+    for _, instance := range group.Instances {
+      if isInstanceHealthy(&instance) {
+        summary.HealthyCount++
+      }
+    }
+    if launchTemplate := getGroupLaunchTemplate(group); launchTemplate != nil {
+      version, err := c.describeLaunchTemplateVersion(ctx, launchTemplate)
+      if err != nil {
+        return nil, err
+      }
+      summary.LaunchTemplateVersion = aws.ToInt64(version.VersionNumber)
+      if version.LaunchTemplateData != nil && version.LaunchTemplateData.ImageId != nil {
+        summary.AMIID = *version.LaunchTemplateData.ImageId
+        imageIDs = append(imageIDs, summary.AMIID)
+      }
+    }
+    summary.LoadBalancerDNSNames, err = c.getLoadBalancerDNSNames(ctx, group.TargetGroupARNs)
+    if err != nil {
+      return nil, err
+    }
+    summaries = append(summaries, summary)
+  }
+  imageNames, err := c.getImageNames(ctx, imageIDs)
+  if err != nil {
+    return nil, err
+  }
+  for _, summary := range summaries {
+    summary.AMIName = imageNames[summary.AMIID]
+  }
+  return summaries, nil
+}
+
+func formatCreatedTime(t *time.Time) string {
+  if t == nil {
+    return ""
+  }
+  return t.UTC().Format(time.RFC3339)
+}
+
+func (s *GroupSummary) getName() string {
+  if s.Untagged {
+    return s.Name + " (untagged)"
+  }
+  return s.Name
+}
+
+func (s *GroupSummary) getAMI() string {
+  if s.AMIName != "" {
+    return s.AMIName
+  }
+  return s.AMIID
+}
+
+func WriteGroupSummaries(out io.Writer, summaries []*GroupSummary, format OutputFormat) error {
+  switch format {
+  case OutputFormatTable:
+    w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "REGION\tGROUP\tMIN/DESIRED/MAX\tHEALTHY\tAMI\tLOAD BALANCER\tCREATED")
+    for _, s := range summaries {
+      fmt.Fprintf(w, "%s\t%s\t%d/%d/%d\t%d/%d\t%s\t%s\t%s\n", s.Region, s.getName(), s.MinSize, s.DesiredCapacity, s.MaxSize, s.HealthyCount, s.InstancesCount, s.getAMI(), strings.Join(s.LoadBalancerDNSNames, ", "), formatCreatedTime(s.CreatedTime))
+    }
+    return w.Flush()
+  case OutputFormatJSON:
+    encoder := json.NewEncoder(out)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(summaries)
+  case OutputFormatCSV:
+    w := csv.NewWriter(out)
+    w.Write([]string{"region", "group", "min_size", "desired_capacity", "max_size", "instances_count", "healthy_count", "launch_template_version", "ami_id", "ami_name", "load_balancer_dns_names", "created_time", "untagged"})
+    for _, s := range summaries {
+      w.Write([]string{
+        s.Region,
+        s.Name,
+        strconv.Itoa(int(s.MinSize)),
+        strconv.Itoa(int(s.DesiredCapacity)),
+        strconv.Itoa(int(s.MaxSize)),
+        strconv.Itoa(s.InstancesCount),
+        strconv.Itoa(s.HealthyCount),
+        strconv.FormatInt(s.LaunchTemplateVersion, 10),
+        s.AMIID,
+        s.AMIName,
+        strings.Join(s.LoadBalancerDNSNames, " "),
+        formatCreatedTime(s.CreatedTime),
+        strconv.FormatBool(s.Untagged),
+      })
+    }
+    w.Flush()
+    return w.Error()
+  }
+  return fmt.Errorf("unknown output format %q, expected %q, %q or %q", format, OutputFormatTable, OutputFormatJSON, OutputFormatCSV)
+}
+
+func (b *Builder) List(ctx context.Context, options ListOptions) ([]*GroupSummary, error) {
+  awsConfig, err := b.getAWSConfig(ctx)
+  if err != nil {
+    return nil, err
+  }
+  regions := options.Regions
+  if len(regions) == 0 {
+    regions = []string{awsConfig.Region}
+  }
+  var summaries []*GroupSummary
+  for _, region := range regions {
+    regionConfig := awsConfig.Copy()
+    regionConfig.Region = region
+    regionSummaries, err := NewClient(regionConfig, &RunConfig{}).ListGroups(ctx, options.Tags)
+    if err != nil {
+      return nil, err
+    }
+    summaries = append(summaries, regionSummaries...)
+  }
+  sort.SliceStable(summaries, func(i, j int) bool {
+    if summaries[i].Region != summaries[j].Region {
+      return summaries[i].Region < summaries[j].Region
+    }
+    return summaries[i].Name < summaries[j].Name
+  })
+  return summaries, nil
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "strings"
+  "time"
+)
+
+const (
+  attributeAccessLogsEnabled    = "access_logs.s3.enabled"
+  attributeAccessLogsBucket     = "access_logs.s3.bucket"
+  attributeAccessLogsPrefix     = "access_logs.s3.prefix"
+  attributeDeletionProtection   = "deletion_protection.enabled"
+  attributeIdleTimeout          = "idle_timeout.timeout_seconds"
+  attributeHTTP2                = "routing.http2.enabled"
+  attributeDropInvalidHeaders   = "routing.http.drop_invalid_header_fields.enabled"
+  attributeDesyncMitigationMode = "routing.http.desync_mitigation_mode"
+
+  minIdleTimeout = time.Second
+  maxIdleTimeout = 4000 * time.Second
+)
+
+var desyncMitigationModes = []string{"monitor", "defensive", "strictest"}
+
+type LoadBalancerAttributes struct {
+  AccessLogsBucket     string
+  AccessLogsPrefix     string
+  DeletionProtection   bool
+  IdleTimeout          time.Duration
+  HTTP2                bool
+  DropInvalidHeaders   bool
+  DesyncMitigationMode string
+}
+
+func DefaultLoadBalancerAttributes() LoadBalancerAttributes {
+  return LoadBalancerAttributes{
+    IdleTimeout:          60 * time.Second,
+    HTTP2:                true,
+    DesyncMitigationMode: "defensive",
+  }
+}
+
+func (a *LoadBalancerAttributes) validate() error {
+  if a.AccessLogsPrefix != "" && a.AccessLogsBucket == "" {
+    return fmt.Errorf("the access logs prefix %q requires the access logs bucket", a.AccessLogsPrefix)
+  }
+  if strings.HasPrefix(a.AccessLogsPrefix, "/") || strings.HasSuffix(a.AccessLogsPrefix, "/") {
+    return fmt.Errorf("the access logs prefix %q must not begin or end with a slash", a.AccessLogsPrefix)
+  }
+  if a.IdleTimeout < minIdleTimeout || a.IdleTimeout > maxIdleTimeout {
+    return fmt.Errorf("the load balancer idle timeout %v is out of the %v-%v range", a.IdleTimeout, minIdleTimeout, maxIdleTimeout)
+  }
+  for _, mode := range desyncMitigationModes {
+    if a.DesyncMitigationMode == mode {
+      return nil
+    }
+  }
+  return fmt.Errorf("unknown desync mitigation mode %q, expected one of %s", a.DesyncMitigationMode, strings.Join(desyncMitigationModes, ", "))
+}
+
+func (a *LoadBalancerAttributes) makeAttributes() []types.LoadBalancerAttribute {
+  var attributes []types.LoadBalancerAttribute
+  add := func(key string, value interface{}) {
+    attributes = append(attributes, types.LoadBalancerAttribute{
+      Key:   aws.String(key),
+      Value: aws.String(fmt.Sprint(value)),
+    })
+  }
+  add(attributeAccessLogsEnabled, a.AccessLogsBucket != "")
+  if a.AccessLogsBucket != "" {
+    add(attributeAccessLogsBucket, a.AccessLogsBucket)
+    add(attributeAccessLogsPrefix, a.AccessLogsPrefix)
+  }
+  add(attributeDeletionProtection, a.DeletionProtection)
+  add(attributeIdleTimeout, int(a.IdleTimeout.Seconds()))
+  add(attributeHTTP2, a.HTTP2)
+  add(attributeDropInvalidHeaders, a.DropInvalidHeaders)
+  add(attributeDesyncMitigationMode, a.DesyncMitigationMode)
+  return attributes
+}
+
+func (c *Client) setLoadBalancerAttributes(ctx context.Context, loadBalancerARN string, attributes []types.LoadBalancerAttribute) error {
+  return c.withRetries(ctx, "modify the load balancer attributes", func() error {
+    _, err := c.elbClient.ModifyLoadBalancerAttributes(ctx, &elasticloadbalancingv2.ModifyLoadBalancerAttributesInput{
+      LoadBalancerArn: aws.String(loadBalancerARN),
+      Attributes:      attributes,
+    }, withoutELBRetries)
+    return err
+  })
+}
+
+func (c *Client) applyLoadBalancerAttributes(ctx context.Context, loadBalancerARN string) error {
+  if c.rc.LoadBalancerAttributes == nil {
+    return nil
+  }
+  if err := c.setLoadBalancerAttributes(ctx, loadBalancerARN, c.rc.LoadBalancerAttributes.makeAttributes()); err != nil {
+    return fmt.Errorf("cannot set up the attributes of the load balancer %q: %w", c.rc.GetBalancerName(), err)
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepLoadBalancer,
+    ResourceType: resourceLoadBalancer,
+    ResourceID:   loadBalancerARN,
+    State:        "configured",
+    Message:      fmt.Sprintf("set up the attributes of the load balancer %q", c.rc.GetBalancerName()),
+  })
+  return nil
+}
+
+func (c *Client) describeLoadBalancerAttributes(ctx context.Context, loadBalancerARN string) (map[string]string, error) {
+  res, err := c.elbClient.DescribeLoadBalancerAttributes(ctx, &elasticloadbalancingv2.DescribeLoadBalancerAttributesInput{
+    LoadBalancerArn: aws.String(loadBalancerARN),
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the attributes of the balancer %s: %w", loadBalancerARN, err)
+  }
+  attributes := map[string]string{}
+  for _, attribute := range res.Attributes {
+    attributes[aws.ToString(attribute.Key)] = aws.ToString(attribute.Value)
+  }
+  return attributes, nil
+}
+
+func (c *Client) liftDeletionProtection(ctx context.Context, loadBalancerARN string) error {
+  attributes, err := c.describeLoadBalancerAttributes(ctx, loadBalancerARN)
+  if err != nil {
+    return err
+  }
+  if attributes[attributeDeletionProtection] != "true" {
+    return nil
+  }
+  err = c.setLoadBalancerAttributes(ctx, loadBalancerARN, []types.LoadBalancerAttribute{
+    {
+      Key:   aws.String(attributeDeletionProtection),
+      Value: aws.String("false"),
+    },
+  })
+  if err != nil {
+    return fmt.Errorf("cannot lift the deletion protection of the balancer %s: %w", loadBalancerARN, err)
+  }
+  c.emitCleanup(resourceLoadBalancer, loadBalancerARN, "unprotected", fmt.Sprintf("lifted the deletion protection of the load balancer %s", loadBalancerARN))
+  return nil
+}
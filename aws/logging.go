@@ -0,0 +1,74 @@
+package aws
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "sync"
+  "time"
+)
+
+type LogFormat string
+
+const (
+  LogFormatText LogFormat = "text"
+  LogFormatJSON LogFormat = "json"
+)
+
+type jsonLogWriter struct {
+  mutex sync.Mutex
+  out   io.Writer
+}
+
+func (w *jsonLogWriter) writeEvent(event *Event) error {
+  line, err := json.Marshal(event)
+  if err != nil {
+    return err
+  }
+  w.mutex.Lock()
+  defer w.mutex.Unlock()
+  _, err = w.out.Write(append(line, '\n'))
+  return err
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+  event := &Event{
+    Time:    time.Now(),
+    Message: string(bytes.TrimRight(p, "\n")),
+  }
+  if err := w.writeEvent(event); err != nil {
+    return 0, err
+  }
+  return len(p), nil
+}
+
+var jsonLogger *jsonLogWriter
+
+func SetLogFormat(format LogFormat) error {
+  switch format {
+  case LogFormatText:
+    jsonLogger = nil
+    log.SetFlags(log.LstdFlags)
+    log.SetOutput(os.Stderr)
+  case LogFormatJSON:
+    jsonLogger = &jsonLogWriter{out: os.Stderr}
+    log.SetFlags(0)
+    log.SetOutput(jsonLogger)
+  default:
+    return fmt.Errorf("unknown log format %q, expected %q or %q", format, LogFormatText, LogFormatJSON)
+  }
+  return nil
+}
+
+func logEvent(event *Event) {
+  if jsonLogger == nil {
+    log.Print(event.Message)
+    return
+  }
+  if err := jsonLogger.writeEvent(event); err != nil {
+    log.Print(event.Message)
+  }
+}
@@ -0,0 +1,125 @@
+package aws
+
+import (
+  "bytes"
+  "crypto/sha1"
+  "encoding/hex"
+  "fmt"
+  "strings"
+  "text/template"
+)
+
+const (
+  maxELBNameLength = 32
+  nameHashLength   = 6
+)
+
+type NamingTemplates struct {
+  Group          string
+  LaunchTemplate string
+  TargetGroup    string
+  Balancer       string
+  AMI            string
+}
+
+func DefaultNamingTemplates() NamingTemplates {
+  return NamingTemplates{
+    Group:          "{{.Group}}",
+    LaunchTemplate: "{{.Group}}",
+    TargetGroup:    "{{.Group}}",
+    Balancer:       "{{.Group}}",
+    AMI:            "{{.Group}} v1",
+  }
+}
+
+type namingData struct {
+  Group string
+  Env   string
+}
+
+type artifactNames struct {
+  group          string
+  launchTemplate string
+  targetGroup    string
+  balancer       string
+  ami            string
+}
+
+func renderName(title string, nameTemplate string, data *namingData) (string, error) {
+  t, err := template.New(title).Option("missingkey=error").Parse(nameTemplate)
+  if err != nil {
+    return "", fmt.Errorf("cannot parse the %s name template %q: %w", title, nameTemplate, err)
+  }
+  var name bytes.Buffer
+  if err := t.Execute(&name, data); err != nil {
+    return "", fmt.Errorf("cannot render the %s name template %q: %w", title, nameTemplate, err)
+  }
+  if name.Len() == 0 {
+    return "", fmt.Errorf("the %s name template %q renders to an empty name", title, nameTemplate)
+  }
+  return name.String(), nil
+}
+
+func shortenELBName(name string) string {
+  name = strings.ReplaceAll(name, "_", "-")
+  if len(name) <= maxELBNameLength {
+    return name
+  }
+  hash := sha1.Sum([]byte(name))
+  prefix := strings.TrimRight(name[:maxELBNameLength-nameHashLength-1], "-")
+  return prefix + "-" + hex.EncodeToString(hash[:])[:nameHashLength]
+}
+
+func (c *RunConfig) renderNames() (artifactNames, error) {
+  templates := c.NamingTemplates
+  defaults := DefaultNamingTemplates()
+  for _, t := range []struct {
+    value        *string
+    defaultValue string
+  }{
+    {&templates.Group, defaults.Group},
+    {&templates.LaunchTemplate, defaults.LaunchTemplate},
+    {&templates.TargetGroup, defaults.TargetGroup},
+    {&templates.Balancer, defaults.Balancer},
+    {&templates.AMI, defaults.AMI},
+  } {
+    if *t.value == "" {
+      *t.value = t.defaultValue
+    }
+  }
+  data := &namingData{
+    Group: c.GroupName,
+    Env:   c.Env,
+  }
+  var names artifactNames
+  var err error
+  if names.group, err = renderName("auto scaling group", templates.Group, data); err != nil {
+    return names, err
+  }
+  if names.launchTemplate, err = renderName("launch template", templates.LaunchTemplate, data); err != nil {
+    return names, err
+  }
+  if names.targetGroup, err = renderName("target group", templates.TargetGroup, data); err != nil {
+    return names, err
+  }
+  names.targetGroup = shortenELBName(names.targetGroup)
+  if names.balancer, err = renderName("load balancer", templates.Balancer, data); err != nil {
+    return names, err
+  }
+  names.balancer = shortenELBName(names.balancer)
+  if names.ami, err = renderName("AMI", templates.AMI, data); err != nil {
+    return names, err
+  }
+  return names, nil
+}
+
+func (c *RunConfig) getNames() *artifactNames {
+  if c.names == nil {
+    names, err := c.renderNames()
+    if err != nil {
+      return &names
+    }
+    c.names = &names
+  }
+  return c.names
+}
@@ -0,0 +1,43 @@
+package aws
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestShortenELBName(t *testing.T) {
+  for _, test := range []struct {
+    name string
+    want string
+  }{
+    {name: "my-service", want: "my-service"},
+    {name: "my_service_group", want: "my-service-group"},
+    {name: strings.Repeat("a", maxELBNameLength), want: strings.Repeat("a", maxELBNameLength)},
+    {name: "my_service_group_in_the_production_environment", want: "my-service-group-in-the-p-83cdc3"},
+  } {
+    if got := shortenELBName(test.name); got != test.want {
+      t.Errorf("expected %q to be shortened to %q, got %q", test.name, test.want, got)
+    }
+  }
+}
+
+func TestShortenELBNameKeepsNamesDistinct(t *testing.T) {
+  first := shortenELBName("my-service-group-in-the-production-environment-a")
+  second := shortenELBName("my-service-group-in-the-production-environment-b")
+  if len(first) > maxELBNameLength || len(second) > maxELBNameLength {
+    t.Fatalf("expected the names to fit %d symbols, got %q and %q", maxELBNameLength, first, second)
+  }
+  if first == second {
+    t.Fatalf("expected different names for different inputs, got %q twice", first)
+  }
+}
+
+func TestShortenELBNameTrimsTrailingHyphens(t *testing.T) {
+  name := shortenELBName(strings.Repeat("a", maxELBNameLength-nameHashLength-2) + "--" + strings.Repeat("b", 10))
+  if strings.Contains(name, "--") {
+    t.Fatalf("expected no double hyphens before the hash, got %q", name)
+  }
+  if err := validateELBName(name, "load balancer"); err != nil {
+    t.Fatalf("expected a valid load balancer name, got %v", err)
+  }
+}
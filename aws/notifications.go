@@ -0,0 +1,120 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "sort"
+  "strings"
+)
+
+var notificationTypes = []struct {
+  name    string
+  awsName string
+}{
+  {"launch", "autoscaling:EC2_INSTANCE_LAUNCH"},
+  {"terminate", "autoscaling:EC2_INSTANCE_TERMINATE"},
+  {"launch-error", "autoscaling:EC2_INSTANCE_LAUNCH_ERROR"},
+  {"terminate-error", "autoscaling:EC2_INSTANCE_TERMINATE_ERROR"},
+}
+
+func DefaultNotificationTypes() string {
+  return "launch,terminate,launch-error"
+}
+
+func ParseNotificationTypes(value string) ([]string, error) {
+  var awsNames []string
+  seen := map[string]bool{}
+  for _, part := range strings.Split(value, ",") {
+    part = strings.TrimSpace(part)
+    awsName := ""
+    var known []string
+    for _, notificationType := range notificationTypes {
+      if part == notificationType.name {
+        awsName = notificationType.awsName
+      }
+      known = append(known, notificationType.name)
+    }
+    if awsName == "" {
+      return nil, fmt.Errorf("unknown notification type %q, expected one of %s", part, strings.Join(known, ", "))
+    }
+    if !seen[awsName] {
+      seen[awsName] = true
+      awsNames = append(awsNames, awsName)
+    }
+  }
+  return awsNames, nil
+}
+
+func (c *RunConfig) HasNotifications() bool {
+  return c.NotificationTopicARN != ""
+}
+
+func (c *Client) PutNotificationConfiguration(ctx context.Context) error {
+  err := c.withRetries(ctx, "put a notification configuration", func() error {
+    _, err := c.autoscalingClient.PutNotificationConfiguration(ctx, &autoscaling.PutNotificationConfigurationInput{
+      AutoScalingGroupName: aws.String(c.rc.GetGroupName()),
+      NotificationTypes:    c.rc.NotificationTypes,
+      TopicARN:             aws.String(c.rc.NotificationTopicARN),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot set up the notifications of the group %q to the topic %s: %w", c.rc.GetGroupName(), c.rc.NotificationTopicARN, err)
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepAutoScalingGroup,
+    ResourceType: resourceNotifications,
+    ResourceID:   c.rc.NotificationTopicARN,
+    State:        "created",
+    Message:      fmt.Sprintf("group %q notifies the topic %s about %s", c.rc.GetGroupName(), c.rc.NotificationTopicARN, strings.Join(c.rc.NotificationTypes, ", ")),
+  })
+  return nil
+}
+
+func (c *Client) describeNotificationConfigurations(ctx context.Context, groupName string) ([]types.NotificationConfiguration, error) {
+  var configurations []types.NotificationConfiguration
+  var nextToken *string
+  for {
+    res, err := c.autoscalingClient.DescribeNotificationConfigurations(ctx, &autoscaling.DescribeNotificationConfigurationsInput{
+      AutoScalingGroupNames: []string{groupName},
+      NextToken:             nextToken,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the notification configurations of the group %q: %w", groupName, err)
+    }
+    configurations = append(configurations, res.NotificationConfigurations...)
+    nextToken = res.NextToken
+    if nextToken == nil {
+      break
+    }
+  }
+  return configurations, nil
+}
+
+func (s *Stack) getNotificationTypes(topicARN string) []string {
+  var awsNames []string
+  for _, configuration := range s.NotificationConfigurations {
+    if aws.ToString(configuration.TopicARN) == topicARN {
+      awsNames = append(awsNames, aws.ToString(configuration.NotificationType))
+    }
+  }
+  return awsNames
+}
+
+func (s *Stack) getNotificationTopicARNs() []string {
+  var topicARNs []string
+  seen := map[string]bool{}
+  for _, configuration := range s.NotificationConfigurations {
+    topicARN := aws.ToString(configuration.TopicARN)
+    if !seen[topicARN] {
+      seen[topicARN] = true
+      topicARNs = append(topicARNs, topicARN)
+    }
+  }
+  sort.Strings(topicARNs)
+  return topicARNs
+}
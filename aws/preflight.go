@@ -0,0 +1,333 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "log"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+const minBalancerAvailabilityZones = 2
+
+func (c *Client) checkInstanceState(instanceData *ec2types.Instance) error {
+  if instanceData.State == nil || instanceData.State.Name != ec2types.InstanceStateNameRunning {
+    state := "unknown"
+    if instanceData.State != nil {
+      state = string(instanceData.State.Name)
+    }
+    return fmt.Errorf("the instance %s is %s, not running", c.rc.InstanceID, state)
+  }
+  return nil
+}
+
+func (c *Client) checkAutoScalingGroupAbsent(ctx context.Context) error {
+  res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+    AutoScalingGroupNames: []string{c.rc.GetGroupName()},
+  })
+  if err != nil {
+    return fmt.Errorf("cannot check whether the auto scaling group %q exists: %w", c.rc.GetGroupName(), err)
+  }
+  if len(res.AutoScalingGroups) != 0 {
+    return fmt.Errorf("the auto scaling group %q already exists", c.rc.GetGroupName())
+  }
+  return nil
+}
+
+func (c *Client) checkLaunchTemplateAbsent(ctx context.Context) error {
+  res, err := c.ec2Client.DescribeLaunchTemplates(ctx, &ec2.DescribeLaunchTemplatesInput{
+    Filters: []ec2types.Filter{
+      {
+        Name:   aws.String("launch-template-name"),
+        Values: []string{c.rc.GetLaunchTemplateName()},
+      },
+    },
+  })
+  if err != nil {
+    return fmt.Errorf("cannot check whether the launch template %q exists: %w", c.rc.GetLaunchTemplateName(), err)
+  }
+  if len(res.LaunchTemplates) != 0 {
+    return fmt.Errorf("the launch template %q already exists (%s)", c.rc.GetLaunchTemplateName(), *res.LaunchTemplates[0].LaunchTemplateId)
+  }
+  return nil
+}
+
+func (c *Client) checkTargetGroupAbsent(ctx context.Context) error {
+  res, err := c.elbClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+    Names: []string{c.rc.GetTargetGroupName()},
+  })
+  var notFound *elbtypes.TargetGroupNotFoundException
+  if errors.As(err, &notFound) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("cannot check whether the target group %q exists: %w", c.rc.GetTargetGroupName(), err)
+  }
+  if len(res.TargetGroups) != 0 {
+    return fmt.Errorf("the target group %q already exists (%s)", c.rc.GetTargetGroupName(), *res.TargetGroups[0].TargetGroupArn)
+  }
+  return nil
+}
+
+func (c *Client) checkLoadBalancerAbsent(ctx context.Context) error {
+  res, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+    Names: []string{c.rc.GetBalancerName()},
+  })
+  var notFound *elbtypes.LoadBalancerNotFoundException
+  if errors.As(err, &notFound) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("cannot check whether the load balancer %q exists: %w", c.rc.GetBalancerName(), err)
+  }
+  if len(res.LoadBalancers) != 0 {
+    return fmt.Errorf("the load balancer %q already exists (%s)", c.rc.GetBalancerName(), *res.LoadBalancers[0].LoadBalancerArn)
+  }
+  return nil
+}
+
+func (c *Client) getSubnetZones(ctx context.Context, subnetIDs []string) ([]string, error) {
+  res, err := c.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+    SubnetIds: subnetIDs,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the subnets %s: %w", strings.Join(subnetIDs, ", "), err)
+  }
+  zonesSet := map[string]bool{}
+  for _, subnet := range res.Subnets {
+    zonesSet[*subnet.AvailabilityZone] = true
+  }
+  var zones []string
+  for zone := range zonesSet {
+    zones = append(zones, zone)
+  }
+  sort.Strings(zones)
+  return zones, nil
+}
+
+func (c *Client) getInstanceTypeZones(ctx context.Context, instanceType ec2types.InstanceType) (map[string]bool, error) {
+  zones := map[string]bool{}
+  var nextToken *string
+  for {
+    res, err := c.ec2Client.DescribeInstanceTypeOfferings(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+      Filters: []ec2types.Filter{
+        {
+          Name:   aws.String("instance-type"),
+          Values: []string{string(instanceType)},
+        },
+      },
+      LocationType: ec2types.LocationTypeAvailabilityZone,
+      NextToken:    nextToken,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the offerings of the instance type %s: %w", instanceType, err)
+    }
+    for _, offering := range res.InstanceTypeOfferings {
+      zones[*offering.Location] = true
+    }
+    if res.NextToken == nil {
+      break
+    }
+    nextToken = res.NextToken
+  }
+  return zones, nil
+}
+
+func (c *Client) checkSubnetZones(ctx context.Context, instanceData *ec2types.Instance, subnetIDs []string) []string {
+  if len(subnetIDs) == 0 {
+    return []string{"no default subnets found"}
+  }
+  zones, err := c.getSubnetZones(ctx, subnetIDs)
+  if err != nil {
+    return []string{err.Error()}
+  }
+  var problems []string
+  if !c.rc.UsesExistingBalancer() && len(zones) < minBalancerAvailabilityZones {
+    problems = append(problems, fmt.Sprintf("the default subnets cover %d availability zones, the load balancer needs at least %d", len(zones), minBalancerAvailabilityZones))
+  }
+  offeredZones, err := c.getInstanceTypeZones(ctx, instanceData.InstanceType)
+  if err != nil {
+    return append(problems, err.Error())
+  }
+  for _, zone := range zones {
+    if !offeredZones[zone] {
+      problems = append(problems, fmt.Sprintf("the instance type %s is not offered in the availability zone %s", instanceData.InstanceType, zone))
+    }
+  }
+  return problems
+}
+
+func (c *Client) checkKeyPair(ctx context.Context, instanceData *ec2types.Instance) error {
+  if instanceData.KeyName == nil {
+    return nil
+  }
+  res, err := c.ec2Client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{
+    Filters: []ec2types.Filter{
+      {
+        Name:   aws.String("key-name"),
+        Values: []string{*instanceData.KeyName},
+      },
+    },
+  })
+  if err != nil {
+    return fmt.Errorf("cannot check whether the key pair %q exists: %w", *instanceData.KeyName, err)
+  }
+  if len(res.KeyPairs) == 0 {
+    return fmt.Errorf("the key pair %q of the instance %s doesn't exist", *instanceData.KeyName, c.rc.InstanceID)
+  }
+  return nil
+}
+
+func (c *Client) getELBLimits(ctx context.Context) (map[string]int, error) {
+  limits := map[string]int{}
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeAccountLimits(ctx, &elasticloadbalancingv2.DescribeAccountLimitsInput{
+      Marker: marker,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the load balancing account limits: %w", err)
+    }
+    for _, limit := range res.Limits {
+      value, err := strconv.Atoi(*limit.Max)
+      if err != nil {
+        return nil, fmt.Errorf("cannot parse the load balancing account limit %s = %q: %w", *limit.Name, *limit.Max, err)
+      }
+      limits[*limit.Name] = value
+    }
+    if res.NextMarker == nil {
+      break
+    }
+    marker = res.NextMarker
+  }
+  return limits, nil
+}
+
+func (c *Client) countApplicationLoadBalancers(ctx context.Context) (int, error) {
+  count := 0
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+      Marker: marker,
+    })
+    if err != nil {
+      return 0, fmt.Errorf("cannot describe the load balancers: %w", err)
+    }
+    for _, loadBalancer := range res.LoadBalancers {
+      if loadBalancer.Type == elbtypes.LoadBalancerTypeEnumApplication {
+        count++
+      }
+    }
+    if res.NextMarker == nil {
+      break
+    }
+    marker = res.NextMarker
+  }
+  return count, nil
+}
+
+func (c *Client) countTargetGroups(ctx context.Context) (int, error) {
+  count := 0
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+      Marker: marker,
+    })
+    if err != nil {
+      return 0, fmt.Errorf("cannot describe the target groups: %w", err)
+    }
+    count += len(res.TargetGroups)
+    if res.NextMarker == nil {
+      break
+    }
+    marker = res.NextMarker
+  }
+  return count, nil
+}
+
+func (c *Client) checkQuotas(ctx context.Context) []string {
+  var problems []string
+  autoscalingLimits, err := c.autoscalingClient.DescribeAccountLimits(ctx, &autoscaling.DescribeAccountLimitsInput{})
+  if err != nil {
+    problems = append(problems, fmt.Sprintf("cannot describe the auto scaling account limits: %v", err))
+  } else if *autoscalingLimits.NumberOfAutoScalingGroups >= *autoscalingLimits.MaxNumberOfAutoScalingGroups {
+    problems = append(problems, fmt.Sprintf("the account already has %d of %d auto scaling groups allowed", *autoscalingLimits.NumberOfAutoScalingGroups, *autoscalingLimits.MaxNumberOfAutoScalingGroups))
+  }
+  elbLimits, err := c.getELBLimits(ctx)
+  if err != nil {
+    return append(problems, err.Error())
+  }
+  if maxTargetGroups, ok := elbLimits["target-groups"]; ok {
+    numTargetGroups, err := c.countTargetGroups(ctx)
+    if err != nil {
+      problems = append(problems, err.Error())
+    } else if numTargetGroups >= maxTargetGroups {
+      problems = append(problems, fmt.Sprintf("the account already has %d of %d target groups allowed", numTargetGroups, maxTargetGroups))
+    }
+  }
+  if maxBalancers, ok := elbLimits["application-load-balancers"]; ok && !c.rc.UsesExistingBalancer() {
+    numBalancers, err := c.countApplicationLoadBalancers(ctx)
+    if err != nil {
+      problems = append(problems, err.Error())
+    } else if numBalancers >= maxBalancers {
+      problems = append(problems, fmt.Sprintf("the account already has %d of %d application load balancers allowed", numBalancers, maxBalancers))
+    }
+  }
+  return problems
+}
+
+func (c *Client) checkExistingBalancerVPC(ctx context.Context, instanceData *ec2types.Instance) error {
+  loadBalancer, err := c.describeExistingLoadBalancer(ctx)
+  if err != nil {
+    return err
+  }
+  if aws.ToString(loadBalancer.VpcId) != aws.ToString(instanceData.VpcId) {
+    return fmt.Errorf("the load balancer %q is in VPC %s, but the target group would be created in VPC %s of the instance %s", c.rc.ExistingBalancer, aws.ToString(loadBalancer.VpcId), aws.ToString(instanceData.VpcId), c.rc.InstanceID)
+  }
+  return nil
+}
+
+func (c *Client) checkDNSRecordReplaceable(ctx context.Context) error {
+  recordSets, err := c.findDNSRecordSets(ctx)
+  if err != nil {
+    return err
+  }
+  return c.checkDNSRecordSets(recordSets)
+}
+
+func (c *Client) RunPreflightChecks(ctx context.Context, instanceData *ec2types.Instance, subnetIDs []string) error {
+  var problems []string
+  addProblem := func(err error) {
+    if err != nil {
+      problems = append(problems, err.Error())
+    }
+  }
+  addProblem(c.checkInstanceState(instanceData))
+  addProblem(c.checkAutoScalingGroupAbsent(ctx))
+  addProblem(c.checkLaunchTemplateAbsent(ctx))
+  addProblem(c.checkTargetGroupAbsent(ctx))
+  if c.rc.UsesExistingBalancer() {
+    addProblem(c.checkExistingBalancerVPC(ctx, instanceData))
+  } else {
+    addProblem(c.checkLoadBalancerAbsent(ctx))
+  }
+  if c.rc.HasDNSRecord() {
+    addProblem(c.checkDNSRecordReplaceable(ctx))
+  }
+  problems = append(problems, c.checkSubnetZones(ctx, instanceData, subnetIDs)...)
+  addProblem(c.checkKeyPair(ctx, instanceData))
+  problems = append(problems, c.checkQuotas(ctx)...)
+  if len(problems) != 0 {
+    return fmt.Errorf("%d preflight checks failed:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+  }
+  log.Printf("all preflight checks passed")
+  return nil
+}
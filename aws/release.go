@@ -0,0 +1,297 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+  cloudwatchtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "log"
+  "strings"
+  "time"
+)
+
+const stepTrafficRoute = "traffic route"
+
+type trafficRoute struct {
+  loadBalancerARN      string
+  listenerARN          string
+  ruleARN              string
+  stableTargetGroupARN string
+}
+
+func getARNResource(arn string, prefix string) string {
+  if index := strings.Index(arn, prefix); index >= 0 {
+    return arn[index:]
+  }
+  return arn
+}
+
+func getListenerLoadBalancerARN(listenerARN string) string {
+  loadBalancerARN := strings.Replace(listenerARN, ":listener/", ":loadbalancer/", 1)
+  return loadBalancerARN[:strings.LastIndex(loadBalancerARN, "/")]
+}
+
+func (c *Client) getTrafficRoute(stack *Stack) (*trafficRoute, error) {
+  targetGroup := stack.findTargetGroup(c.rc.GetTargetGroupName())
+  if targetGroup == nil {
+    return nil, fmt.Errorf("the auto scaling group %q has no target group %q", c.rc.GetGroupName(), c.rc.GetTargetGroupName())
+  }
+  route := &trafficRoute{stableTargetGroupARN: *targetGroup.TargetGroupArn}
+  if c.rc.UsesExistingBalancer() {
+    listenerRule := stack.findListenerRule(c.rc.GetListenerPort())
+    if listenerRule == nil {
+      return nil, fmt.Errorf("the target group %q has no listener rule on port %d", c.rc.GetTargetGroupName(), c.rc.GetListenerPort())
+    }
+    route.ruleARN = *listenerRule.Rule.RuleArn
+    route.listenerARN = listenerRule.ListenerARN
+    route.loadBalancerARN = getListenerLoadBalancerARN(listenerRule.ListenerARN)
+    return route, nil
+  }
+  loadBalancer := c.findServingLoadBalancer(stack)
+  if loadBalancer == nil {
+    return nil, fmt.Errorf("the target group %q is not served by any load balancer", c.rc.GetTargetGroupName())
+  }
+  listener := stack.findListener(*loadBalancer.LoadBalancerArn, c.rc.GetListenerPort())
+  if listener == nil {
+    return nil, fmt.Errorf("the load balancer %q has no listener on port %d", aws.ToString(loadBalancer.LoadBalancerName), c.rc.GetListenerPort())
+  }
+  route.loadBalancerARN = *loadBalancer.LoadBalancerArn
+  route.listenerARN = *listener.ListenerArn
+  return route, nil
+}
+
+func makeWeightedForwardActions(targetGroups ...elbtypes.TargetGroupTuple) []elbtypes.Action {
+  return []elbtypes.Action{
+    {
+      Type: elbtypes.ActionTypeEnumForward,
+      ForwardConfig: &elbtypes.ForwardActionConfig{
+        TargetGroups: targetGroups,
+      },
+    },
+  }
+}
+
+func (c *Client) setRouteActions(ctx context.Context, route *trafficRoute, actions []elbtypes.Action) error {
+  return c.withRetries(ctx, "shift the load balancer weights", func() error {
+    if route.ruleARN != "" {
+      _, err := c.elbClient.ModifyRule(ctx, &elasticloadbalancingv2.ModifyRuleInput{
+        RuleArn: aws.String(route.ruleARN),
+        Actions: actions,
+      }, withoutELBRetries)
+      return err
+    }
+    _, err := c.elbClient.ModifyListener(ctx, &elasticloadbalancingv2.ModifyListenerInput{
+      ListenerArn:    aws.String(route.listenerARN),
+      DefaultActions: actions,
+    }, withoutELBRetries)
+    return err
+  })
+}
+
+func (c *Client) setNextWeight(ctx context.Context, route *trafficRoute, percent int32) error {
+  actions := makeWeightedForwardActions(
+    elbtypes.TargetGroupTuple{TargetGroupArn: aws.String(route.stableTargetGroupARN), Weight: aws.Int32(100 - percent)},
+    elbtypes.TargetGroupTuple{TargetGroupArn: aws.String(c.targetGroupARN), Weight: aws.Int32(percent)},
+  )
+  if err := c.setRouteActions(ctx, route, actions); err != nil {
+    return fmt.Errorf("cannot shift %d%% of the traffic to the target group %q: %w", percent, c.rc.GetTargetGroupName(), err)
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepTrafficRoute,
+    ResourceType: "target group",
+    ResourceID:   c.targetGroupARN,
+    State:        fmt.Sprintf("%d%%", percent),
+    Message:      fmt.Sprintf("target group %q receives %d%% of the traffic", c.rc.GetTargetGroupName(), percent),
+  })
+  return nil
+}
+
+func (c *Client) forwardAllTraffic(ctx context.Context, route *trafficRoute, targetGroupARN string) error {
+  actions := makeWeightedForwardActions(elbtypes.TargetGroupTuple{TargetGroupArn: aws.String(targetGroupARN), Weight: aws.Int32(1)})
+  if err := c.setRouteActions(ctx, route, actions); err != nil {
+    return fmt.Errorf("cannot forward all the traffic to the target group %s: %w", targetGroupARN, err)
+  }
+  return nil
+}
+
+func (c *Client) getMetricSum(ctx context.Context, metricName string, route *trafficRoute, startTime time.Time) (float64, error) {
+  res, err := c.cloudwatchClient.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+    Namespace:  aws.String("AWS/ApplicationELB"),
+    MetricName: aws.String(metricName),
+    Dimensions: []cloudwatchtypes.Dimension{
+      {Name: aws.String("LoadBalancer"), Value: aws.String(getARNResource(route.loadBalancerARN, "app/"))},
+      {Name: aws.String("TargetGroup"), Value: aws.String(getARNResource(c.targetGroupARN, "targetgroup/"))},
+    },
+    StartTime:  aws.Time(startTime),
+    EndTime:    aws.Time(time.Now()),
+    Period:     aws.Int32(60),
+    Statistics: []cloudwatchtypes.Statistic{cloudwatchtypes.StatisticSum},
+  })
+  if err != nil {
+    return 0, fmt.Errorf("cannot get the %s metric of the target group %q: %w", metricName, c.rc.GetTargetGroupName(), err)
+  }
+  sum := 0.0
+  for _, datapoint := range res.Datapoints {
+    sum += aws.ToFloat64(datapoint.Sum)
+  }
+  return sum, nil
+}
+
+func (c *Client) checkNextHealth(ctx context.Context, route *trafficRoute, startTime time.Time, maxErrorRate float64) error {
+  res, err := c.elbClient.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+    TargetGroupArn: aws.String(c.targetGroupARN),
+  })
+  if err != nil {
+    return fmt.Errorf("cannot describe the target health of the target group %q: %w", c.rc.GetTargetGroupName(), err)
+  }
+  numHealthy := int32(0)
+  for _, description := range res.TargetHealthDescriptions {
+    if description.TargetHealth != nil && description.TargetHealth.State == elbtypes.TargetHealthStateEnumHealthy {
+      numHealthy++
+    }
+  }
+  if numHealthy < c.rc.InstancesCount {
+    return fmt.Errorf("only %d of %d targets of the target group %q are healthy", numHealthy, c.rc.InstancesCount, c.rc.GetTargetGroupName())
+  }
+  requests, err := c.getMetricSum(ctx, "RequestCount", route, startTime)
+  if err != nil {
+    return err
+  }
+  if requests == 0 {
+    return nil
+  }
+  errorCount, err := c.getMetricSum(ctx, "HTTPCode_Target_5XX_Count", route, startTime)
+  if err != nil {
+    return err
+  }
+  if errorRate := errorCount / requests; errorRate > maxErrorRate {
+    return fmt.Errorf("the error rate %.4f of the target group %q exceeds the threshold %.4f (%g errors of %g requests)", errorRate, c.rc.GetTargetGroupName(), maxErrorRate, errorCount, requests)
+  }
+  return nil
+}
+
+func (c *Client) observeNext(ctx context.Context, route *trafficRoute, duration time.Duration, maxErrorRate float64, stage string) error {
+  startTime := time.Now()
+  finishTime := startTime.Add(duration)
+  for {
+    if err := c.checkNextHealth(ctx, route, startTime, maxErrorRate); err != nil {
+      return fmt.Errorf("the group %q failed at %s: %w", c.rc.GetGroupName(), stage, err)
+    }
+    if !time.Now().Before(finishTime) {
+      return nil
+    }
+    log.Printf("group %q: %s, healthy, %v left", c.rc.GetGroupName(), stage, time.Until(finishTime).Round(time.Second))
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
+  }
+}
+
+func (c *Client) buildNextStack(ctx context.Context, instanceData *ec2types.Instance, subnetIDs []string, route *trafficRoute) error {
+  c.buildSteps = c.makeBuildSteps(instanceData, subnetIDs, &buildStep{
+    name:      stepTrafficRoute,
+    dependsOn: []string{stepTargetGroup},
+    run: func(ctx context.Context) error {
+      return c.setNextWeight(ctx, route, 0)
+    },
+  })
+  return runBuildSteps(ctx, c.buildSteps, c.emit)
+}
+
+func (c *Client) rollBackRelease(route *trafficRoute, releaseErr error) error {
+  ctx, cancel := c.newCleanupContext()
+  defer cancel()
+  log.Printf("rolling the release %q back: %v", c.rc.GetGroupName(), releaseErr)
+  if err := c.forwardAllTraffic(ctx, route, route.stableTargetGroupARN); err != nil {
+    return fmt.Errorf("%w; %v, the canary artifacts are kept", releaseErr, err)
+  }
+  c.Cleanup(ctx)
+  return releaseErr
+}
+
+func (c *Client) retireStableGroup(stable *Client, stack *Stack, route *trafficRoute) error {
+  ctx, cancel := stable.newCleanupContext()
+  defer cancel()
+  stable.autoScalingGroupCreationStarted = true
+  stable.targetGroupARN = route.stableTargetGroupARN
+  if version := stack.LaunchTemplateVersion; version != nil {
+    stable.launchTemplateID = aws.ToString(version.LaunchTemplateId)
+    if version.LaunchTemplateData != nil {
+      stable.amiID = aws.ToString(version.LaunchTemplateData.ImageId)
+    }
+  }
+  report := stable.Cleanup(ctx)
+  if !report.Succeeded() {
+    return fmt.Errorf("cannot retire the auto scaling group %q: %s", stable.rc.GetGroupName(), report)
+  }
+  log.Printf("retired the auto scaling group %q, the service is served by %q", stable.rc.GetGroupName(), c.rc.GetGroupName())
+  return nil
+}
+
+func (c *Client) runReleasePreflightChecks(ctx context.Context, instanceData *ec2types.Instance) error {
+  var problems []string
+  for _, err := range []error{
+    c.checkInstanceState(instanceData),
+    c.checkAutoScalingGroupAbsent(ctx),
+    c.checkLaunchTemplateAbsent(ctx),
+    c.checkTargetGroupAbsent(ctx),
+  } {
+    if err != nil {
+      problems = append(problems, err.Error())
+    }
+  }
+  if len(problems) != 0 {
+    return fmt.Errorf("%d preflight checks failed:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+  }
+  return nil
+}
+
+func (c *Client) prepareRelease(ctx context.Context, stable *Client) (*Stack, *trafficRoute, *ec2types.Instance, error) {
+  stack, err := stable.DescribeStack(ctx, stable.rc.GetGroupName())
+  if err != nil {
+    return nil, nil, nil, err
+  }
+  route, err := stable.getTrafficRoute(stack)
+  if err != nil {
+    return nil, nil, nil, err
+  }
+  instanceData, err := c.DescribeInstance(ctx)
+  if err != nil {
+    return nil, nil, nil, err
+  }
+  if err := c.runReleasePreflightChecks(ctx, instanceData); err != nil {
+    return nil, nil, nil, err
+  }
+  return stack, route, instanceData, nil
+}
+
+func (b *Builder) newReleaseClients(ctx context.Context, spec *RunConfig, nextGroupName string) (*Client, *Client, error) {
+  if nextGroupName == "" {
+    return nil, nil, fmt.Errorf("the name of the new group is required")
+  }
+  nextSpec := *spec
+  nextSpec.GroupName = nextGroupName
+  if err := spec.ValidateArtifactNames(); err != nil {
+    return nil, nil, err
+  }
+  if err := nextSpec.ValidateArtifactNames(); err != nil {
+    return nil, nil, err
+  }
+  if nextSpec.GetGroupName() == spec.GetGroupName() {
+    return nil, nil, fmt.Errorf("the new group name %q renders to the name of the current group", nextGroupName)
+  }
+  stable, err := b.newClient(ctx, spec)
+  if err != nil {
+    return nil, nil, err
+  }
+  next, err := b.newClient(ctx, &nextSpec)
+  if err != nil {
+    return nil, nil, err
+  }
+  return stable, next, nil
+}
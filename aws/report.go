@@ -0,0 +1,123 @@
+package aws
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "time"
+)
+
+type ReportResource struct {
+  Type string `json:"type"`
+  ID   string `json:"id"`
+  Name string `json:"name,omitempty"`
+  Link string `json:"link,omitempty"`
+}
+
+type ReportStep struct {
+  Name            string     `json:"name"`
+  Status          string     `json:"status"`
+  StartTime       *time.Time `json:"start_time,omitempty"`
+  FinishTime      *time.Time `json:"finish_time,omitempty"`
+  DurationSeconds float64    `json:"duration_seconds,omitempty"`
+}
+
+type Report struct {
+  Success             bool              `json:"success"`
+  Error               string            `json:"error,omitempty"`
+  Region              string            `json:"region"`
+  GroupName           string            `json:"group_name"`
+  Resources           []*ReportResource `json:"resources"`
+  LoadBalancerDNSName string            `json:"load_balancer_dns_name,omitempty"`
+  DNSName             string            `json:"dns_name,omitempty"`
+  HealthURL           string            `json:"health_url,omitempty"`
+  Steps               []*ReportStep     `json:"steps"`
+  StartTime           time.Time         `json:"start_time"`
+  TotalSeconds        float64           `json:"total_seconds"`
+  Cleanup             *CleanupReport    `json:"cleanup,omitempty"`
+}
+
+func (c *Client) getReportResources() []*ReportResource {
+  var resources []*ReportResource
+  if c.amiID != "" {
+    resources = append(resources, &ReportResource{Type: "AMI", ID: c.amiID, Name: c.rc.GetAMIName(), Link: c.GetAMILink(c.amiID)})
+  }
+  if c.launchTemplateID != "" {
+    resources = append(resources, &ReportResource{Type: "launch template", ID: c.launchTemplateID, Name: c.rc.GetLaunchTemplateName(), Link: c.GetLaunchTemplateLink(c.launchTemplateID)})
+  }
+  if c.targetGroupARN != "" {
+    resources = append(resources, &ReportResource{Type: "target group", ID: c.targetGroupARN, Name: c.rc.GetTargetGroupName(), Link: c.GetTargetGroupLink(c.targetGroupARN)})
+  }
+  if c.loadBalancerARN != "" {
+    resources = append(resources, &ReportResource{Type: "load balancer", ID: c.loadBalancerARN, Name: c.loadBalancerName, Link: c.GetLoadBalancerLink()})
+  }
+  if c.listenerRuleARN != "" {
+    resources = append(resources, &ReportResource{Type: "listener rule", ID: c.listenerRuleARN, Link: c.GetLoadBalancerLink()})
+  }
+  if len(c.dnsRecordTypes) != 0 {
+    resources = append(resources, &ReportResource{Type: "DNS record", ID: c.rc.DNSName, Name: c.rc.DNSName})
+  }
+  if c.autoScalingGroupCreationStarted {
+    resources = append(resources, &ReportResource{Type: "auto scaling group", ID: c.rc.GetGroupName(), Name: c.rc.GetGroupName(), Link: c.GetAutoScalingGroupLink()})
+  }
+  return resources
+}
+
+func (c *Client) getReportSteps() []*ReportStep {
+  var steps []*ReportStep
+  for _, step := range c.buildSteps {
+    reportStep := &ReportStep{
+      Name:   step.name,
+      Status: step.getStatus(),
+    }
+    if !step.startTime.IsZero() {
+      startTime := step.startTime
+      reportStep.StartTime = &startTime
+    }
+    if !step.finishTime.IsZero() {
+      finishTime := step.finishTime
+      reportStep.FinishTime = &finishTime
+      reportStep.DurationSeconds = finishTime.Sub(step.startTime).Seconds()
+    }
+    steps = append(steps, reportStep)
+  }
+  return steps
+}
+
+func (c *Client) MakeReport(buildErr error) *Report {
+  report := &Report{
+    Success:             buildErr == nil,
+    Region:              c.region,
+    GroupName:           c.rc.GetGroupName(),
+    Resources:           c.getReportResources(),
+    LoadBalancerDNSName: c.loadBalancerDNSName,
+    Steps:               c.getReportSteps(),
+    StartTime:           c.startTime,
+    TotalSeconds:        time.Since(c.startTime).Seconds(),
+    Cleanup:             c.cleanupReport,
+  }
+  if buildErr != nil {
+    report.Error = buildErr.Error()
+  }
+  if len(c.dnsRecordTypes) != 0 {
+    report.DNSName = c.rc.DNSName
+  }
+  if c.loadBalancerDNSName != "" {
+    report.HealthURL = c.getHealthURL()
+  }
+  return report
+}
+
+func (c *Client) WriteReport(buildErr error) error {
+  if c.rc.ReportPath == "" {
+    return nil
+  }
+  reportJSON, err := json.MarshalIndent(c.MakeReport(buildErr), "", "  ")
+  if err != nil {
+    return fmt.Errorf("cannot marshal the report: %w", err)
+  }
+  if err := os.WriteFile(c.rc.ReportPath, append(reportJSON, '\n'), 0644); err != nil {
+    return fmt.Errorf("cannot write the report to %s: %w", c.rc.ReportPath, err)
+  }
+  return nil
+}
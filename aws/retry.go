@@ -0,0 +1,178 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  "github.com/aws/aws-sdk-go-v2/service/route53"
+  "github.com/aws/smithy-go"
+  "log"
+  "math/rand"
+  "net"
+  "sync"
+  "time"
+)
+
+type errorClass int
+
+const (
+  errorPermanent errorClass = iota
+  errorTransient
+  errorThrottling
+  errorEventualConsistency
+)
+
+func (c errorClass) String() string {
+  switch c {
+  case errorTransient:
+    return "transient"
+  case errorThrottling:
+    return "throttling"
+  case errorEventualConsistency:
+    return "eventual consistency"
+  }
+  return "permanent"
+}
+
+var (
+  throttlingErrorCodes = map[string]bool{
+    "Throttling":                             true,
+    "ThrottlingException":                    true,
+    "ThrottledException":                     true,
+    "RequestThrottled":                       true,
+    "RequestThrottledException":              true,
+    "RequestLimitExceeded":                   true,
+    "TooManyRequestsException":               true,
+    "PriorRequestNotComplete":                true,
+    "SlowDown":                               true,
+    "EC2ThrottledException":                  true,
+    "ProvisionedThroughputExceededException": true,
+  }
+  eventualConsistencyErrorCodes = map[string]bool{
+    "InvalidAMIID.NotFound":                       true,
+    "InvalidAMIID.Unavailable":                    true,
+    "InvalidInstanceID.NotFound":                  true,
+    "InvalidLaunchTemplateId.NotFound":            true,
+    "InvalidLaunchTemplateId.VersionNotFound":     true,
+    "InvalidLaunchTemplateName.NotFoundException": true,
+    "LoadBalancerNotFound":                        true,
+    "TargetGroupNotFound":                         true,
+  }
+
+  jitterMutex sync.Mutex
+  jitterRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+type RetryPolicy struct {
+  MaxAttempts int
+  BaseDelay   time.Duration
+  MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+  return RetryPolicy{
+    MaxAttempts: 5,
+    BaseDelay:   time.Second,
+    MaxDelay:    30 * time.Second,
+  }
+}
+
+func classifyError(err error) errorClass {
+  if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+    return errorPermanent
+  }
+  var apiError smithy.APIError
+  if !errors.As(err, &apiError) {
+    var netError net.Error
+    if errors.As(err, &netError) {
+      return errorTransient
+    }
+    return errorPermanent
+  }
+  code := apiError.ErrorCode()
+  if throttlingErrorCodes[code] {
+    return errorThrottling
+  }
+  if eventualConsistencyErrorCodes[code] {
+    return errorEventualConsistency
+  }
+  if apiError.ErrorFault() == smithy.FaultServer {
+    return errorTransient
+  }
+  return errorPermanent
+}
+
+func withoutAutoScalingRetries(o *autoscaling.Options) {
+  o.Retryer = aws.NopRetryer{}
+}
+
+func withoutEC2Retries(o *ec2.Options) {
+  o.Retryer = aws.NopRetryer{}
+}
+
+func withoutELBRetries(o *elasticloadbalancingv2.Options) {
+  o.Retryer = aws.NopRetryer{}
+}
+
+func withoutRoute53Retries(o *route53.Options) {
+  o.Retryer = aws.NopRetryer{}
+}
+
+func isRetryable(err error) bool {
+  return classifyError(err) != errorPermanent
+}
+
+func (p *RetryPolicy) getDelay(attempt int) time.Duration {
+  delay := p.BaseDelay
+  for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+    delay *= 2
+  }
+  if delay > p.MaxDelay {
+    delay = p.MaxDelay
+  }
+  if delay <= 0 {
+    return 0
+  }
+  jitterMutex.Lock()
+  defer jitterMutex.Unlock()
+  return delay/2 + time.Duration(jitterRand.Int63n(int64(delay/2)+1))
+}
+
+func (c *Client) getRetryPolicy() RetryPolicy {
+  if c.rc.RetryPolicy == (RetryPolicy{}) {
+    return DefaultRetryPolicy()
+  }
+  return c.rc.RetryPolicy
+}
+
+func (c *Client) withRetries(ctx context.Context, title string, call func() error) error {
+  policy := c.getRetryPolicy()
+  maxAttempts := policy.MaxAttempts
+  if maxAttempts < 1 {
+    maxAttempts = 1
+  }
+  for attempt := 1; ; attempt++ {
+    if err := ctx.Err(); err != nil {
+      return err
+    }
+    err := call()
+    if err == nil {
+      return nil
+    }
+    if ctx.Err() != nil {
+      return ctx.Err()
+    }
+    errorClass := classifyError(err)
+    if errorClass == errorPermanent || attempt >= maxAttempts {
+      return err
+    }
+    delay := policy.getDelay(attempt)
+    log.Printf("cannot %s (attempt %d of %d, %s error), retrying in %v: %v", title, attempt, maxAttempts, errorClass, delay.Round(time.Millisecond), err)
+    if err := sleepContext(ctx, delay); err != nil {
+      return err
+    }
+  }
+}
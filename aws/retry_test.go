@@ -0,0 +1,60 @@
+package aws
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/aws/smithy-go"
+  "net"
+  "testing"
+  "time"
+)
+
+func TestClassifyError(t *testing.T) {
+  for _, test := range []struct {
+    name string
+    err  error
+    want errorClass
+  }{
+    {name: "throttling", err: &smithy.GenericAPIError{Code: "Throttling", Fault: smithy.FaultClient}, want: errorThrottling},
+    {name: "wrapped throttling", err: fmt.Errorf("cannot create the group: %w", &smithy.GenericAPIError{Code: "RequestLimitExceeded"}), want: errorThrottling},
+    {name: "eventual consistency", err: &smithy.GenericAPIError{Code: "InvalidAMIID.NotFound", Fault: smithy.FaultClient}, want: errorEventualConsistency},
+    {name: "server fault", err: &smithy.GenericAPIError{Code: "InternalFailure", Fault: smithy.FaultServer}, want: errorTransient},
+    {name: "client fault", err: &smithy.GenericAPIError{Code: "ValidationError", Fault: smithy.FaultClient}, want: errorPermanent},
+    {name: "network error", err: fmt.Errorf("cannot send the request: %w", &net.DNSError{Err: "no such host", Name: "ec2.amazonaws.com", IsTimeout: true}), want: errorTransient},
+    {name: "cancelled context", err: fmt.Errorf("cannot send the request: %w", context.Canceled), want: errorPermanent},
+    {name: "deadline", err: context.DeadlineExceeded, want: errorPermanent},
+    {name: "plain error", err: errors.New("the group has no subnets"), want: errorPermanent},
+  } {
+    if got := classifyError(test.err); got != test.want {
+      t.Errorf("%s: expected the %s class, got %s", test.name, test.want, got)
+    }
+  }
+}
+
+func TestGetDelay(t *testing.T) {
+  policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+  for _, test := range []struct {
+    attempt int
+    want    time.Duration
+  }{
+    {attempt: 1, want: time.Second},
+    {attempt: 2, want: 2 * time.Second},
+    {attempt: 3, want: 4 * time.Second},
+    {attempt: 4, want: 5 * time.Second},
+    {attempt: 100, want: 5 * time.Second},
+  } {
+    for i := 0; i < 100; i++ {
+      if delay := policy.getDelay(test.attempt); delay < test.want/2 || delay > test.want {
+        t.Fatalf("expected the delay of the attempt %d to be within %v-%v, got %v", test.attempt, test.want/2, test.want, delay)
+      }
+    }
+  }
+}
+
+func TestGetDelayWithoutBaseDelay(t *testing.T) {
+  policy := RetryPolicy{MaxAttempts: 3}
+  if delay := policy.getDelay(2); delay != 0 {
+    t.Fatalf("expected no delay, got %v", delay)
+  }
+}
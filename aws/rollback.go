@@ -0,0 +1,205 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "log"
+  "time"
+)
+
+const stepRollback = "rollback"
+
+type RollbackOptions struct {
+  ToVersion     int64
+  UpdateTimeout time.Duration
+  UpdateTick    time.Duration
+}
+
+type RollbackResult struct {
+  GroupName          string `json:"group_name"`
+  LaunchTemplateID   string `json:"launch_template_id"`
+  LaunchTemplateName string `json:"launch_template_name"`
+  FromVersion        int64  `json:"from_version"`
+  ToVersion          int64  `json:"to_version"`
+  InstanceRefreshID  string `json:"instance_refresh_id"`
+  ImageID            string `json:"image_id"`
+  ImageName          string `json:"image_name,omitempty"`
+}
+
+func (c *Client) getPreviousLaunchTemplateVersion(ctx context.Context, current *ec2types.LaunchTemplateVersion) (*ec2types.LaunchTemplateVersion, error) {
+  currentVersion := aws.ToInt64(current.VersionNumber)
+  var previous *ec2types.LaunchTemplateVersion
+  var nextToken *string
+  for {
+    res, err := c.ec2Client.DescribeLaunchTemplateVersions(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+      LaunchTemplateId: current.LaunchTemplateId,
+      MaxVersion:       aws.String(fmt.Sprintf("%d", currentVersion-1)),
+      NextToken:        nextToken,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the versions of the launch template %s: %w", aws.ToString(current.LaunchTemplateId), err)
+    }
+    for i := range res.LaunchTemplateVersions {
+      version := &res.LaunchTemplateVersions[i]
+      if aws.ToInt64(version.VersionNumber) >= currentVersion {
+        continue
+      }
+      if previous == nil || aws.ToInt64(version.VersionNumber) > aws.ToInt64(previous.VersionNumber) {
+        previous = version
+      }
+    }
+    nextToken = res.NextToken
+    if nextToken == nil {
+      break
+    }
+  }
+  if previous == nil {
+    return nil, fmt.Errorf("the launch template %s has no versions before the current version %d; the groups built by this tool start with version 1, use a canary or a blue/green release to replace their AMI", aws.ToString(current.LaunchTemplateId), currentVersion)
+  }
+  return previous, nil
+}
+
+func (c *Client) getRollbackTarget(ctx context.Context, current *ec2types.LaunchTemplateVersion, toVersion int64) (*ec2types.LaunchTemplateVersion, error) {
+  if toVersion == 0 {
+    return c.getPreviousLaunchTemplateVersion(ctx, current)
+  }
+  if toVersion == aws.ToInt64(current.VersionNumber) {
+    return nil, fmt.Errorf("the launch template version %d is already the current one", toVersion)
+  }
+  return c.describeLaunchTemplateVersion(ctx, &autoscalingtypes.LaunchTemplateSpecification{
+    LaunchTemplateId: current.LaunchTemplateId,
+    Version:          aws.String(fmt.Sprintf("%d", toVersion)),
+  })
+}
+
+func (c *Client) startInstanceRefresh(ctx context.Context, groupName string) (string, error) {
+  var res *autoscaling.StartInstanceRefreshOutput
+  err := c.withRetries(ctx, "start an instance refresh", func() (err error) {
+    res, err = c.autoscalingClient.StartInstanceRefresh(ctx, &autoscaling.StartInstanceRefreshInput{
+      AutoScalingGroupName: aws.String(groupName),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return "", fmt.Errorf("cannot start an instance refresh of the group %q: %w", groupName, err)
+  }
+  return aws.ToString(res.InstanceRefreshId), nil
+}
+
+func (c *Client) waitForInstanceRefresh(ctx context.Context, groupName string, instanceRefreshID string) error {
+  finishTime := time.Now().Add(c.rc.UpdateTimeout)
+  for time.Now().Before(finishTime) {
+    res, err := c.autoscalingClient.DescribeInstanceRefreshes(ctx, &autoscaling.DescribeInstanceRefreshesInput{
+      AutoScalingGroupName: aws.String(groupName),
+      InstanceRefreshIds:   []string{instanceRefreshID},
+    })
+    if err != nil {
+      if !isRetryable(err) {
+        return fmt.Errorf("cannot get description of the instance refresh %s: %w", instanceRefreshID, err)
+      }
+      log.Printf("cannot get description of the instance refresh %s: %v", instanceRefreshID, err)
+      if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+        return err
+      }
+      continue
+    }
+    if len(res.InstanceRefreshes) != 1 {
+      return fmt.Errorf("received wrong %d != 1 number of instance refreshes with id %s", len(res.InstanceRefreshes), instanceRefreshID)
+    }
+    refresh := res.InstanceRefreshes[0]
+    c.emit(&Event{
+      Kind:         EventKindResourceState,
+      Step:         stepRollback,
+      ResourceType: "instance refresh",
+      ResourceID:   instanceRefreshID,
+      State:        string(refresh.Status),
+      Message:      fmt.Sprintf("instance refresh %s: %s, %d%% complete", instanceRefreshID, refresh.Status, aws.ToInt32(refresh.PercentageComplete)),
+    })
+    switch refresh.Status {
+    case autoscalingtypes.InstanceRefreshStatusSuccessful:
+      return nil
+    case autoscalingtypes.InstanceRefreshStatusFailed, autoscalingtypes.InstanceRefreshStatusCancelling, autoscalingtypes.InstanceRefreshStatusCancelled:
+      return fmt.Errorf("the instance refresh %s ended up in the status %q: %s", instanceRefreshID, refresh.Status, aws.ToString(refresh.StatusReason))
+    }
+    if err := sleepContext(ctx, c.rc.UpdateTick); err != nil {
+      return err
+    }
+  }
+  return fmt.Errorf("the instance refresh %s has not completed within the timeout %v", instanceRefreshID, c.rc.UpdateTimeout)
+}
+
+func (c *Client) Rollback(ctx context.Context, groupName string, toVersion int64) (*RollbackResult, error) {
+  group, err := c.describeAutoScalingGroup(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  if group.LaunchTemplate == nil {
+    return nil, fmt.Errorf("the auto scaling group %q does not use a launch template directly", groupName)
+  }
+  current, err := c.describeLaunchTemplateVersion(ctx, group.LaunchTemplate)
+  if err != nil {
+    return nil, err
+  }
+  target, err := c.getRollbackTarget(ctx, current, toVersion)
+  if err != nil {
+    return nil, err
+  }
+  result := &RollbackResult{
+    GroupName:          groupName,
+    LaunchTemplateID:   aws.ToString(target.LaunchTemplateId),
+    LaunchTemplateName: aws.ToString(target.LaunchTemplateName),
+    FromVersion:        aws.ToInt64(current.VersionNumber),
+    ToVersion:          aws.ToInt64(target.VersionNumber),
+  }
+  if target.LaunchTemplateData != nil {
+    result.ImageID = aws.ToString(target.LaunchTemplateData.ImageId)
+  }
+  log.Printf("rolling the group %q back from the launch template %q version %d to version %d", groupName, result.LaunchTemplateName, result.FromVersion, result.ToVersion)
+  err = c.withRetries(ctx, "update the auto scaling group", func() error {
+    _, err := c.autoscalingClient.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+      AutoScalingGroupName: aws.String(groupName),
+      LaunchTemplate: &autoscalingtypes.LaunchTemplateSpecification{
+        LaunchTemplateId: target.LaunchTemplateId,
+        Version:          aws.String(fmt.Sprintf("%d", result.ToVersion)),
+      },
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot point the auto scaling group %q at the launch template version %d: %w", groupName, result.ToVersion, err)
+  }
+  result.InstanceRefreshID, err = c.startInstanceRefresh(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  if err := c.waitForInstanceRefresh(ctx, groupName, result.InstanceRefreshID); err != nil {
+    return nil, err
+  }
+  if result.ImageID != "" {
+    imageNames, err := c.getImageNames(ctx, []string{result.ImageID})
+    if err != nil {
+      return nil, err
+    }
+    result.ImageName = imageNames[result.ImageID]
+  }
+  log.Printf("the group %q is now served by the AMI %s (%q) from the launch template version %d", groupName, result.ImageID, result.ImageName, result.ToVersion)
+  return result, nil
+}
+
+func (b *Builder) Rollback(ctx context.Context, groupName string, options RollbackOptions) (*RollbackResult, error) {
+  c, err := b.newClient(ctx, &RunConfig{
+    GroupName:     groupName,
+    UpdateTimeout: options.UpdateTimeout,
+    UpdateTick:    options.UpdateTick,
+    RetryPolicy:   DefaultRetryPolicy(),
+  })
+  if err != nil {
+    return nil, err
+  }
+  return c.Rollback(ctx, groupName, options.ToVersion)
+}
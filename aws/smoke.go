@@ -0,0 +1,160 @@
+package aws
+
+import (
+  "context"
+  "crypto/tls"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "net"
+  "net/http"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+const (
+  smokeRequestTimeout = 10 * time.Second
+  maxSmokeBodySize    = 1 << 20
+)
+
+type SmokeCheck struct {
+  Path           string
+  ExpectedStatus int
+  BodyRegExp     *regexp.Regexp
+}
+
+func ParseSmokeCheck(description string) (*SmokeCheck, error) {
+  parts := strings.SplitN(description, ",", 3)
+  check := &SmokeCheck{
+    Path:           parts[0],
+    ExpectedStatus: http.StatusOK,
+  }
+  if !strings.HasPrefix(check.Path, "/") {
+    return nil, fmt.Errorf("the smoke check path %q must begin with a slash", check.Path)
+  }
+  if len(parts) > 1 && parts[1] != "" {
+    status, err := strconv.Atoi(parts[1])
+    if err != nil {
+      return nil, fmt.Errorf("cannot parse the expected status %q of the smoke check %q: %w", parts[1], description, err)
+    }
+    check.ExpectedStatus = status
+  }
+  if len(parts) > 2 && parts[2] != "" {
+    bodyRegExp, err := regexp.Compile(parts[2])
+    if err != nil {
+      return nil, fmt.Errorf("cannot compile the body regexp %q of the smoke check %q: %w", parts[2], description, err)
+    }
+    check.BodyRegExp = bodyRegExp
+  }
+  return check, nil
+}
+
+func validateSmokeSuccesses(requiredSuccesses int) error {
+  if requiredSuccesses < 1 {
+    return fmt.Errorf("the smoke test requires at least 1 successful round, got %d", requiredSuccesses)
+  }
+  return nil
+}
+
+type SmokeTest struct {
+  BaseURL           string
+  Host              string
+  Checks            []*SmokeCheck
+  RequiredSuccesses int
+  Interval          time.Duration
+  Timeout           time.Duration
+  HTTPClient        *http.Client
+}
+
+func (t *SmokeTest) runCheck(ctx context.Context, check *SmokeCheck) error {
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.BaseURL+check.Path, nil)
+  if err != nil {
+    return fmt.Errorf("cannot create a request to %s: %w", check.Path, err)
+  }
+  if t.Host != "" {
+    req.Host = t.Host
+  }
+  resp, err := t.HTTPClient.Do(req)
+  if err != nil {
+    var dnsError *net.DNSError
+    if errors.As(err, &dnsError) {
+      return fmt.Errorf("%s is not resolved yet", dnsError.Name)
+    }
+    return fmt.Errorf("cannot get %s: %w", check.Path, err)
+  }
+  defer resp.Body.Close()
+  body, err := io.ReadAll(io.LimitReader(resp.Body, maxSmokeBodySize))
+  if err != nil {
+    return fmt.Errorf("cannot read the response body of %s: %w", check.Path, err)
+  }
+  if resp.StatusCode != check.ExpectedStatus {
+    return fmt.Errorf("%s returned status %d, expected %d", check.Path, resp.StatusCode, check.ExpectedStatus)
+  }
+  if check.BodyRegExp != nil && !check.BodyRegExp.Match(body) {
+    return fmt.Errorf("the response body of %s doesn't match %q", check.Path, check.BodyRegExp.String())
+  }
+  return nil
+}
+
+func (t *SmokeTest) runChecks(ctx context.Context) error {
+  for _, check := range t.Checks {
+    if err := t.runCheck(ctx, check); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (t *SmokeTest) Run(ctx context.Context) error {
+  if err := validateSmokeSuccesses(t.RequiredSuccesses); err != nil {
+    return err
+  }
+  finishTime := time.Now().Add(t.Timeout)
+  numSuccesses := 0
+  for time.Now().Before(finishTime) {
+    if err := t.runChecks(ctx); err != nil {
+      log.Printf("smoke test of %s: %v", t.BaseURL, err)
+      numSuccesses = 0
+    } else {
+      numSuccesses++
+      log.Printf("smoke test of %s: %d checks passed (%d of %d in a row)", t.BaseURL, len(t.Checks), numSuccesses, t.RequiredSuccesses)
+      if numSuccesses >= t.RequiredSuccesses {
+        return nil
+      }
+    }
+    select {
+    case <-ctx.Done():
+      return ctx.Err()
+    case <-time.After(t.Interval):
+    }
+  }
+  return fmt.Errorf("the smoke test of %s has not passed within the timeout %v", t.BaseURL, t.Timeout)
+}
+
+func (c *Client) RunSmokeTest(ctx context.Context) error {
+  checks := []*SmokeCheck{{Path: c.rc.HealthPath, ExpectedStatus: http.StatusOK}}
+  checks = append(checks, c.rc.SmokeChecks...)
+  smokeTest := &SmokeTest{
+    BaseURL:           c.getServiceURL(),
+    Host:              c.rc.HostHeader,
+    Checks:            checks,
+    RequiredSuccesses: c.rc.SmokeSuccesses,
+    Interval:          c.rc.SmokeInterval,
+    Timeout:           c.rc.SmokeTimeout,
+    HTTPClient:        &http.Client{Timeout: smokeRequestTimeout},
+  }
+  if c.getServiceScheme() == "https" && c.rc.HostHeader != "" {
+    smokeTest.HTTPClient.Transport = &http.Transport{
+      Proxy:           http.ProxyFromEnvironment,
+      TLSClientConfig: &tls.Config{ServerName: c.rc.HostHeader},
+    }
+  }
+  if err := smokeTest.Run(ctx); err != nil {
+    return err
+  }
+  log.Printf("smoke test of %s passed", smokeTest.BaseURL)
+  return nil
+}
@@ -0,0 +1,99 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "regexp"
+  "strings"
+  "testing"
+  "time"
+)
+
+func newTestSmokeTest(baseURL string, checks ...*SmokeCheck) *SmokeTest {
+  return &SmokeTest{
+    BaseURL:           baseURL,
+    Checks:            checks,
+    RequiredSuccesses: 2,
+    Interval:          10 * time.Millisecond,
+    Timeout:           time.Second,
+    HTTPClient:        &http.Client{Timeout: time.Second},
+  }
+}
+
+func TestSmokeTestMatchesStatus(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    if req.URL.Path == "/missing" {
+      http.NotFound(w, req)
+      return
+    }
+    fmt.Fprint(w, "ok")
+  }))
+  defer server.Close()
+
+  if err := newTestSmokeTest(server.URL, &SmokeCheck{Path: "/ping", ExpectedStatus: http.StatusOK}, &SmokeCheck{Path: "/missing", ExpectedStatus: http.StatusNotFound}).Run(context.Background()); err != nil {
+    t.Fatalf("expected the smoke test to pass, got %v", err)
+  }
+  smokeTest := newTestSmokeTest(server.URL, &SmokeCheck{Path: "/ping", ExpectedStatus: http.StatusCreated})
+  if err := smokeTest.runCheck(context.Background(), smokeTest.Checks[0]); err == nil || !strings.Contains(err.Error(), "returned status 200, expected 201") {
+    t.Fatalf("expected a status mismatch, got %v", err)
+  }
+}
+
+func TestSmokeTestMatchesBody(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    fmt.Fprint(w, `{"status": "healthy"}`)
+  }))
+  defer server.Close()
+
+  check := &SmokeCheck{Path: "/health", ExpectedStatus: http.StatusOK, BodyRegExp: regexp.MustCompile(`"status": "healthy"`)}
+  if err := newTestSmokeTest(server.URL, check).Run(context.Background()); err != nil {
+    t.Fatalf("expected the smoke test to pass, got %v", err)
+  }
+  check = &SmokeCheck{Path: "/health", ExpectedStatus: http.StatusOK, BodyRegExp: regexp.MustCompile(`"status": "degraded"`)}
+  smokeTest := newTestSmokeTest(server.URL, check)
+  if err := smokeTest.runCheck(context.Background(), check); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+    t.Fatalf("expected a body mismatch, got %v", err)
+  }
+}
+
+func TestSmokeTestSendsHostHeader(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    if req.Host != "my-service.example.com" {
+      http.NotFound(w, req)
+    }
+  }))
+  defer server.Close()
+
+  smokeTest := newTestSmokeTest(server.URL, &SmokeCheck{Path: "/ping", ExpectedStatus: http.StatusOK})
+  smokeTest.Host = "my-service.example.com"
+  if err := smokeTest.Run(context.Background()); err != nil {
+    t.Fatalf("expected the smoke test to pass, got %v", err)
+  }
+}
+
+func TestSmokeTestTimesOut(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    w.WriteHeader(http.StatusServiceUnavailable)
+  }))
+  defer server.Close()
+
+  smokeTest := newTestSmokeTest(server.URL, &SmokeCheck{Path: "/ping", ExpectedStatus: http.StatusOK})
+  smokeTest.Timeout = 50 * time.Millisecond
+  start := time.Now()
+  if err := smokeTest.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "has not passed within the timeout") {
+    t.Fatalf("expected a timeout, got %v", err)
+  }
+  if elapsed := time.Since(start); elapsed > time.Second {
+    t.Fatalf("expected the smoke test to stop after the timeout, it took %v", elapsed)
+  }
+}
+
+func TestSmokeTestRejectsNoRequiredSuccesses(t *testing.T) {
+  smokeTest := newTestSmokeTest("http://localhost", &SmokeCheck{Path: "/ping", ExpectedStatus: http.StatusOK})
+  smokeTest.RequiredSuccesses = 0
+  if err := smokeTest.Run(context.Background()); err == nil {
+    t.Fatalf("expected an error for zero required successes")
+  }
+}
@@ -0,0 +1,284 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "github.com/aws/aws-sdk-go-v2/service/ec2"
+  ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+type StackListenerRule struct {
+  ListenerARN  string
+  ListenerPort int32
+  Rule         elbtypes.Rule
+}
+
+type Stack struct {
+  Group                      *autoscalingtypes.AutoScalingGroup
+  LaunchTemplateVersion      *ec2types.LaunchTemplateVersion
+  TargetGroups               []elbtypes.TargetGroup
+  LoadBalancers              []elbtypes.LoadBalancer
+  SharedLoadBalancers        []elbtypes.LoadBalancer
+  Listeners                  []elbtypes.Listener
+  ListenerRules              []StackListenerRule
+  LifecycleHooks             []autoscalingtypes.LifecycleHook
+  NotificationConfigurations []autoscalingtypes.NotificationConfiguration
+  LoadBalancerAttributes     map[string]map[string]string
+}
+
+func (s *Stack) HasTargetGroup(targetGroupARN string) bool {
+  for _, targetGroup := range s.TargetGroups {
+    if *targetGroup.TargetGroupArn == targetGroupARN {
+      return true
+    }
+  }
+  return false
+}
+
+func getForwardTargetGroupARNs(actions []elbtypes.Action) []string {
+  var targetGroupARNs []string
+  for _, action := range actions {
+    if action.Type != elbtypes.ActionTypeEnumForward {
+      continue
+    }
+    if action.TargetGroupArn != nil {
+      targetGroupARNs = append(targetGroupARNs, *action.TargetGroupArn)
+      continue
+    }
+    if action.ForwardConfig != nil {
+      for _, targetGroup := range action.ForwardConfig.TargetGroups {
+        targetGroupARNs = append(targetGroupARNs, *targetGroup.TargetGroupArn)
+      }
+    }
+  }
+  return targetGroupARNs
+}
+
+func (s *Stack) forwardsToStack(actions []elbtypes.Action) bool {
+  for _, targetGroupARN := range getForwardTargetGroupARNs(actions) {
+    if s.HasTargetGroup(targetGroupARN) {
+      return true
+    }
+  }
+  return false
+}
+
+func (s *Stack) GetLaunchTemplateData() *ec2types.ResponseLaunchTemplateData {
+  if s.LaunchTemplateVersion == nil || s.LaunchTemplateVersion.LaunchTemplateData == nil {
+    return &ec2types.ResponseLaunchTemplateData{}
+  }
+  return s.LaunchTemplateVersion.LaunchTemplateData
+}
+
+func getGroupLaunchTemplate(group *autoscalingtypes.AutoScalingGroup) *autoscalingtypes.LaunchTemplateSpecification {
+  if group.LaunchTemplate != nil {
+    return group.LaunchTemplate
+  }
+  if group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+    return group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+  }
+  return nil
+}
+
+func isInstanceHealthy(instance *autoscalingtypes.Instance) bool {
+  return instance.LifecycleState == autoscalingtypes.LifecycleStateInService && aws.ToString(instance.HealthStatus) == "Healthy"
+}
+
+func (c *Client) findAutoScalingGroup(ctx context.Context, groupName string) (*autoscalingtypes.AutoScalingGroup, error) {
+  res, err := c.autoscalingClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+    AutoScalingGroupNames: []string{groupName},
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot get description of the autoscaling group %q: %w", groupName, err)
+  }
+  if len(res.AutoScalingGroups) == 0 {
+    return nil, nil
+  }
+  if len(res.AutoScalingGroups) != 1 {
+    return nil, fmt.Errorf("received wrong %d != 1 number of auto scaling groups with name %q", len(res.AutoScalingGroups), groupName)
+  }
+  return &res.AutoScalingGroups[0], nil
+}
+
+func (c *Client) describeAutoScalingGroup(ctx context.Context, groupName string) (*autoscalingtypes.AutoScalingGroup, error) {
+  group, err := c.findAutoScalingGroup(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  if group == nil {
+    return nil, fmt.Errorf("the auto scaling group %q does not exist", groupName)
+  }
+  return group, nil
+}
+
+func (c *Client) describeLaunchTemplateVersion(ctx context.Context, spec *autoscalingtypes.LaunchTemplateSpecification) (*ec2types.LaunchTemplateVersion, error) {
+  version := "$Default"
+  if spec.Version != nil {
+    version = *spec.Version
+  }
+  input := &ec2.DescribeLaunchTemplateVersionsInput{
+    LaunchTemplateId:   spec.LaunchTemplateId,
+    LaunchTemplateName: spec.LaunchTemplateName,
+    Versions:           []string{version},
+  }
+  if input.LaunchTemplateId != nil {
+    input.LaunchTemplateName = nil
+  }
+  res, err := c.ec2Client.DescribeLaunchTemplateVersions(ctx, input)
+  if err != nil {
+    return nil, fmt.Errorf("cannot get description of the launch template version %s: %w", version, err)
+  }
+  if len(res.LaunchTemplateVersions) != 1 {
+    return nil, fmt.Errorf("received wrong %d != 1 number of launch template versions %s", len(res.LaunchTemplateVersions), version)
+  }
+  return &res.LaunchTemplateVersions[0], nil
+}
+
+func (c *Client) describeListeners(ctx context.Context, loadBalancerARN string) ([]elbtypes.Listener, error) {
+  var listeners []elbtypes.Listener
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeListeners(ctx, &elasticloadbalancingv2.DescribeListenersInput{
+      LoadBalancerArn: aws.String(loadBalancerARN),
+      Marker:          marker,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the listeners of the balancer %s: %w", loadBalancerARN, err)
+    }
+    listeners = append(listeners, res.Listeners...)
+    marker = res.NextMarker
+    if marker == nil {
+      break
+    }
+  }
+  return listeners, nil
+}
+
+func (c *Client) describeRules(ctx context.Context, listenerARN string) ([]elbtypes.Rule, error) {
+  var rules []elbtypes.Rule
+  var marker *string
+  for {
+    res, err := c.elbClient.DescribeRules(ctx, &elasticloadbalancingv2.DescribeRulesInput{
+      ListenerArn: aws.String(listenerARN),
+      Marker:      marker,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the rules of the listener %s: %w", listenerARN, err)
+    }
+    rules = append(rules, res.Rules...)
+    marker = res.NextMarker
+    if marker == nil {
+      break
+    }
+  }
+  return rules, nil
+}
+
+func (c *Client) describeStackBalancers(ctx context.Context, stack *Stack) error {
+  loadBalancerARNs := map[string]bool{}
+  var orderedLoadBalancerARNs []string
+  for _, targetGroup := range stack.TargetGroups {
+    for _, loadBalancerARN := range targetGroup.LoadBalancerArns {
+      if !loadBalancerARNs[loadBalancerARN] {
+        loadBalancerARNs[loadBalancerARN] = true
+        orderedLoadBalancerARNs = append(orderedLoadBalancerARNs, loadBalancerARN)
+      }
+    }
+  }
+  if len(orderedLoadBalancerARNs) == 0 {
+    return nil
+  }
+  res, err := c.elbClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+    LoadBalancerArns: orderedLoadBalancerARNs,
+  })
+  if err != nil {
+    return fmt.Errorf("cannot describe the balancers of the target groups: %w", err)
+  }
+  for _, loadBalancer := range res.LoadBalancers {
+    listeners, err := c.describeListeners(ctx, *loadBalancer.LoadBalancerArn)
+    if err != nil {
+      return err
+    }
+    owned := false
+    for _, listener := range listeners {
+      if stack.forwardsToStack(listener.DefaultActions) {
+        owned = true
+        break
+      }
+    }
+    if owned {
+      attributes, err := c.describeLoadBalancerAttributes(ctx, *loadBalancer.LoadBalancerArn)
+      if err != nil {
+        return err
+      }
+      if stack.LoadBalancerAttributes == nil {
+        stack.LoadBalancerAttributes = map[string]map[string]string{}
+      }
+      stack.LoadBalancerAttributes[*loadBalancer.LoadBalancerArn] = attributes
+      stack.LoadBalancers = append(stack.LoadBalancers, loadBalancer)
+      stack.Listeners = append(stack.Listeners, listeners...)
+      continue
+    }
+    stack.SharedLoadBalancers = append(stack.SharedLoadBalancers, loadBalancer)
+    for _, listener := range listeners {
+      rules, err := c.describeRules(ctx, *listener.ListenerArn)
+      if err != nil {
+        return err
+      }
+      for _, rule := range rules {
+        if !rule.IsDefault && stack.forwardsToStack(rule.Actions) {
+          stack.ListenerRules = append(stack.ListenerRules, StackListenerRule{
+            ListenerARN:  *listener.ListenerArn,
+            ListenerPort: aws.ToInt32(listener.Port),
+            Rule:         rule,
+          })
+        }
+      }
+    }
+  }
+  return nil
+}
+
+func (c *Client) DescribeStack(ctx context.Context, groupName string) (*Stack, error) {
+  group, err := c.describeAutoScalingGroup(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  return c.describeGroupStack(ctx, group)
+}
+
+func (c *Client) describeGroupStack(ctx context.Context, group *autoscalingtypes.AutoScalingGroup) (*Stack, error) {
+  groupName := aws.ToString(group.AutoScalingGroupName)
+  stack := &Stack{Group: group}
+  var err error
+  if launchTemplate := getGroupLaunchTemplate(group); launchTemplate != nil {
+    stack.LaunchTemplateVersion, err = c.describeLaunchTemplateVersion(ctx, launchTemplate)
+    if err != nil {
+      return nil, err
+    }
+  }
+  if len(group.TargetGroupARNs) != 0 {
+    res, err := c.elbClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+      TargetGroupArns: group.TargetGroupARNs,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the target groups of the group %q: %w", groupName, err)
+    }
+    stack.TargetGroups = res.TargetGroups
+  }
+  if err := c.describeStackBalancers(ctx, stack); err != nil {
+    return nil, err
+  }
+  if stack.LifecycleHooks, err = c.describeLifecycleHooks(ctx, groupName); err != nil {
+    return nil, err
+  }
+  if stack.NotificationConfigurations, err = c.describeNotificationConfigurations(ctx, groupName); err != nil {
+    return nil, err
+  }
+  return stack, nil
+}
@@ -0,0 +1,347 @@
+package aws
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "io"
+  "sort"
+  "strings"
+  "text/tabwriter"
+  "time"
+)
+
+const maxStatusActivities = 10
+
+type OutputFormat string
+
+const (
+  OutputFormatTable OutputFormat = "table"
+  OutputFormatJSON  OutputFormat = "json"
+  OutputFormatCSV   OutputFormat = "csv"
+)
+
+type InstanceStatus struct {
+  ID               string `json:"id"`
+  AvailabilityZone string `json:"availability_zone"`
+  LifecycleState   string `json:"lifecycle_state"`
+  HealthStatus     string `json:"health_status"`
+  TargetHealth     string `json:"target_health,omitempty"`
+  Protected        bool   `json:"protected_from_scale_in,omitempty"`
+}
+
+type WarmPoolStatus struct {
+  PoolState           string            `json:"pool_state"`
+  Status              string            `json:"status,omitempty"`
+  MinSize             int32             `json:"min_size"`
+  MaxPreparedCapacity int32             `json:"max_prepared_capacity"`
+  ReuseOnScaleIn      bool              `json:"reuse_on_scale_in"`
+  Instances           []*InstanceStatus `json:"instances"`
+}
+
+type LoadBalancerStatus struct {
+  Name    string `json:"name"`
+  ARN     string `json:"arn"`
+  State   string `json:"state"`
+  DNSName string `json:"dns_name"`
+  Shared  bool   `json:"shared,omitempty"`
+}
+
+type InstanceRefreshStatus struct {
+  ID                 string     `json:"id"`
+  Status             string     `json:"status"`
+  StatusReason       string     `json:"status_reason,omitempty"`
+  PercentageComplete int32      `json:"percentage_complete"`
+  InstancesToUpdate  int32      `json:"instances_to_update"`
+  StartTime          *time.Time `json:"start_time,omitempty"`
+}
+
+type ScalingActivity struct {
+  StartTime   *time.Time `json:"start_time,omitempty"`
+  StatusCode  string     `json:"status_code"`
+  Description string     `json:"description"`
+  Cause       string     `json:"cause,omitempty"`
+}
+
+type Status struct {
+  GroupName             string                   `json:"group_name"`
+  MinSize               int32                    `json:"min_size"`
+  MaxSize               int32                    `json:"max_size"`
+  DesiredCapacity       int32                    `json:"desired_capacity"`
+  TerminationPolicies   []string                 `json:"termination_policies"`
+  ScaleInProtection     bool                     `json:"scale_in_protection"`
+  MaxInstanceLifetime   int32                    `json:"max_instance_lifetime_seconds,omitempty"`
+  DefaultCooldown       int32                    `json:"default_cooldown_seconds"`
+  HealthyCount          int                      `json:"healthy_count"`
+  Instances             []*InstanceStatus        `json:"instances"`
+  WarmPool              *WarmPoolStatus          `json:"warm_pool,omitempty"`
+  LoadBalancers         []*LoadBalancerStatus    `json:"load_balancers"`
+  LaunchTemplateID      string                   `json:"launch_template_id,omitempty"`
+  LaunchTemplateName    string                   `json:"launch_template_name,omitempty"`
+  LaunchTemplateVersion int64                    `json:"launch_template_version,omitempty"`
+  AMIID                 string                   `json:"ami_id,omitempty"`
+  InstanceRefreshes     []*InstanceRefreshStatus `json:"instance_refreshes"`
+  Activities            []*ScalingActivity       `json:"activities"`
+}
+
+func (c *Client) getTargetHealth(ctx context.Context, stack *Stack) (map[string][]string, error) {
+  targetHealth := map[string][]string{}
+  for _, targetGroup := range stack.TargetGroups {
+    res, err := c.elbClient.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+      TargetGroupArn: targetGroup.TargetGroupArn,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the target health of the target group %q: %w", *targetGroup.TargetGroupName, err)
+    }
+    for _, description := range res.TargetHealthDescriptions {
+      if description.Target == nil || description.TargetHealth == nil {
+        continue
+      }
+      state := string(description.TargetHealth.State)
+      if len(stack.TargetGroups) > 1 {
+        state = fmt.Sprintf("%s: %s", *targetGroup.TargetGroupName, state)
+      }
+      targetHealth[*description.Target.Id] = append(targetHealth[*description.Target.Id], state)
+    }
+  }
+  return targetHealth, nil
+}
+
+func (c *Client) getInstanceRefreshes(ctx context.Context, groupName string) ([]*InstanceRefreshStatus, error) {
+  res, err := c.autoscalingClient.DescribeInstanceRefreshes(ctx, &autoscaling.DescribeInstanceRefreshesInput{
+    AutoScalingGroupName: aws.String(groupName),
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the instance refreshes of the group %q: %w", groupName, err)
+  }
+  var refreshes []*InstanceRefreshStatus
+  for _, refresh := range res.InstanceRefreshes {
+    switch refresh.Status {
+    case autoscalingtypes.InstanceRefreshStatusPending, autoscalingtypes.InstanceRefreshStatusInProgress, autoscalingtypes.InstanceRefreshStatusCancelling:
+    default:
+      continue
+    }
+    refreshes = append(refreshes, &InstanceRefreshStatus{
+      ID:                 aws.ToString(refresh.InstanceRefreshId),
+      Status:             string(refresh.Status),
+      StatusReason:       aws.ToString(refresh.StatusReason),
+      PercentageComplete: aws.ToInt32(refresh.PercentageComplete),
+      InstancesToUpdate:  aws.ToInt32(refresh.InstancesToUpdate),
+      StartTime:          refresh.StartTime,
+    })
+  }
+  return refreshes, nil
+}
+
+func (c *Client) getScalingActivities(ctx context.Context, groupName string) ([]*ScalingActivity, error) {
+  res, err := c.autoscalingClient.DescribeScalingActivities(ctx, &autoscaling.DescribeScalingActivitiesInput{
+    AutoScalingGroupName: aws.String(groupName),
+    MaxRecords:           aws.Int32(maxStatusActivities),
+  })
+  if err != nil {
+    return nil, fmt.Errorf("cannot describe the scaling activities of the group %q: %w", groupName, err)
+  }
+  var activities []*ScalingActivity
+  for _, activity := range res.Activities {
+    activities = append(activities, &ScalingActivity{
+      StartTime:   activity.StartTime,
+      StatusCode:  string(activity.StatusCode),
+      Description: aws.ToString(activity.Description),
+      Cause:       aws.ToString(activity.Cause),
+    })
+  }
+  return activities, nil
+}
+
+func (c *Client) getWarmPoolStatus(ctx context.Context, group *autoscalingtypes.AutoScalingGroup) (*WarmPoolStatus, error) {
+  config := group.WarmPoolConfiguration
+  if config == nil {
+    return nil, nil
+  }
+  instances, err := c.describeWarmPoolInstances(ctx, aws.ToString(group.AutoScalingGroupName))
+  if err != nil {
+    return nil, err
+  }
+  warmPool := &WarmPoolStatus{
+    PoolState:           string(config.PoolState),
+    Status:              string(config.Status),
+    MinSize:             aws.ToInt32(config.MinSize),
+    MaxPreparedCapacity: defaultWarmPoolMaxPreparedCapacity,
+    ReuseOnScaleIn:      config.InstanceReusePolicy != nil && aws.ToBool(config.InstanceReusePolicy.ReuseOnScaleIn),
+  }
+  if config.MaxGroupPreparedCapacity != nil {
+    warmPool.MaxPreparedCapacity = *config.MaxGroupPreparedCapacity
+  }
+  for _, instance := range instances {
+    warmPool.Instances = append(warmPool.Instances, &InstanceStatus{
+      ID:               *instance.InstanceId,
+      AvailabilityZone: aws.ToString(instance.AvailabilityZone),
+      LifecycleState:   string(instance.LifecycleState),
+      HealthStatus:     aws.ToString(instance.HealthStatus),
+    })
+  }
+  sort.Slice(warmPool.Instances, func(i, j int) bool {
+    return warmPool.Instances[i].ID < warmPool.Instances[j].ID
+  })
+  return warmPool, nil
+}
+
+func (c *Client) GetStatus(ctx context.Context, groupName string) (*Status, error) {
+  stack, err := c.DescribeStack(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  targetHealth, err := c.getTargetHealth(ctx, stack)
+  if err != nil {
+    return nil, err
+  }
+  status := &Status{
+    GroupName:           groupName,
+    MinSize:             aws.ToInt32(stack.Group.MinSize),
+    MaxSize:             aws.ToInt32(stack.Group.MaxSize),
+    DesiredCapacity:     aws.ToInt32(stack.Group.DesiredCapacity),
+    TerminationPolicies: stack.Group.TerminationPolicies,
+    ScaleInProtection:   aws.ToBool(stack.Group.NewInstancesProtectedFromScaleIn),
+    MaxInstanceLifetime: aws.ToInt32(stack.Group.MaxInstanceLifetime),
+    DefaultCooldown:     aws.ToInt32(stack.Group.DefaultCooldown),
+  }
+  for _, instance := range stack.Group.Instances {
+    if isInstanceHealthy(&instance) {
+      status.HealthyCount++
+    }
+    status.Instances = append(status.Instances, &InstanceStatus{
+      ID:               *instance.InstanceId,
+      AvailabilityZone: aws.ToString(instance.AvailabilityZone),
+      LifecycleState:   string(instance.LifecycleState),
+      HealthStatus:     aws.ToString(instance.HealthStatus),
+      TargetHealth:     strings.Join(targetHealth[*instance.InstanceId], ", "),
+      Protected:        aws.ToBool(instance.ProtectedFromScaleIn),
+    })
+  }
+  sort.Slice(status.Instances, func(i, j int) bool {
+    return status.Instances[i].ID < status.Instances[j].ID
+  })
+  for i, loadBalancers := range [][]elbtypes.LoadBalancer{stack.LoadBalancers, stack.SharedLoadBalancers} {
+    for _, loadBalancer := range loadBalancers {
+      loadBalancerStatus := &LoadBalancerStatus{
+        Name:    *loadBalancer.LoadBalancerName,
+        ARN:     *loadBalancer.LoadBalancerArn,
+        DNSName: aws.ToString(loadBalancer.DNSName),
+        Shared:  i == 1,
+      }
+      if loadBalancer.State != nil {
+        loadBalancerStatus.State = string(loadBalancer.State.Code)
+      }
+      status.LoadBalancers = append(status.LoadBalancers, loadBalancerStatus)
+    }
+  }
+  if version := stack.LaunchTemplateVersion; version != nil {
+    status.LaunchTemplateID = aws.ToString(version.LaunchTemplateId)
+    status.LaunchTemplateName = aws.ToString(version.LaunchTemplateName)
+    status.LaunchTemplateVersion = aws.ToInt64(version.VersionNumber)
+    status.AMIID = aws.ToString(stack.GetLaunchTemplateData().ImageId)
+  }
+  status.WarmPool, err = c.getWarmPoolStatus(ctx, stack.Group)
+  if err != nil {
+    return nil, err
+  }
+  status.InstanceRefreshes, err = c.getInstanceRefreshes(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  status.Activities, err = c.getScalingActivities(ctx, groupName)
+  if err != nil {
+    return nil, err
+  }
+  return status, nil
+}
+
+func formatStatusTime(t *time.Time) string {
+  if t == nil {
+    return "-"
+  }
+  return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func (s *Status) writeTable(out io.Writer) error {
+  w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+  fmt.Fprintf(w, "Group:\t%s\n", s.GroupName)
+  fmt.Fprintf(w, "Capacity:\tmin %d, max %d, desired %d, %d of %d instances healthy\n", s.MinSize, s.MaxSize, s.DesiredCapacity, s.HealthyCount, len(s.Instances))
+  protection := "off"
+  if s.ScaleInProtection {
+    protection = "on"
+  }
+  fmt.Fprintf(w, "Termination:\tpolicies %s, scale-in protection %s, max instance lifetime %s, default cooldown %ds\n", strings.Join(s.TerminationPolicies, ", "), protection, formatMaxInstanceLifetime(s.MaxInstanceLifetime), s.DefaultCooldown)
+  if s.LaunchTemplateID != "" {
+    fmt.Fprintf(w, "Launch template:\t%s (%s), version %d\n", s.LaunchTemplateName, s.LaunchTemplateID, s.LaunchTemplateVersion)
+    fmt.Fprintf(w, "AMI:\t%s\n", s.AMIID)
+  }
+  for _, loadBalancer := range s.LoadBalancers {
+    shared := ""
+    if loadBalancer.Shared {
+      shared = ", shared"
+    }
+    fmt.Fprintf(w, "Load balancer:\t%s (%s%s), %s\n", loadBalancer.Name, loadBalancer.State, shared, loadBalancer.DNSName)
+  }
+  if warmPool := s.WarmPool; warmPool != nil {
+    description := formatWarmPool(autoscalingtypes.WarmPoolState(warmPool.PoolState), warmPool.MinSize, warmPool.MaxPreparedCapacity, warmPool.ReuseOnScaleIn)
+    if warmPool.Status != "" {
+      description += ", " + warmPool.Status
+    }
+    fmt.Fprintf(w, "Warm pool:\t%s, %d instances\n", description, len(warmPool.Instances))
+  }
+  for _, refresh := range s.InstanceRefreshes {
+    fmt.Fprintf(w, "Instance refresh:\t%s %s, %d%% complete, %d instances to update, started %s\n", refresh.ID, refresh.Status, refresh.PercentageComplete, refresh.InstancesToUpdate, formatStatusTime(refresh.StartTime))
+  }
+  fmt.Fprintln(w)
+  fmt.Fprintln(w, "INSTANCE\tZONE\tLIFECYCLE\tHEALTH\tTARGET HEALTH\tPROTECTED")
+  for _, instance := range s.Instances {
+    targetHealth := instance.TargetHealth
+    if targetHealth == "" {
+      targetHealth = "-"
+    }
+    protected := "no"
+    if instance.Protected {
+      protected = "yes"
+    }
+    fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", instance.ID, instance.AvailabilityZone, instance.LifecycleState, instance.HealthStatus, targetHealth, protected)
+  }
+  if s.WarmPool != nil && len(s.WarmPool.Instances) != 0 {
+    fmt.Fprintln(w)
+    fmt.Fprintln(w, "WARM POOL INSTANCE\tZONE\tLIFECYCLE\tHEALTH")
+    for _, instance := range s.WarmPool.Instances {
+      fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", instance.ID, instance.AvailabilityZone, instance.LifecycleState, instance.HealthStatus)
+    }
+  }
+  fmt.Fprintln(w)
+  fmt.Fprintln(w, "ACTIVITY STARTED\tSTATUS\tDESCRIPTION")
+  for _, activity := range s.Activities {
+    fmt.Fprintf(w, "%s\t%s\t%s\n", formatStatusTime(activity.StartTime), activity.StatusCode, activity.Description)
+  }
+  return w.Flush()
+}
+
+func (s *Status) Write(out io.Writer, format OutputFormat) error {
+  switch format {
+  case OutputFormatTable:
+    return s.writeTable(out)
+  case OutputFormatJSON:
+    encoder := json.NewEncoder(out)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(s)
+  }
+  return fmt.Errorf("unknown output format %q, expected %q or %q", format, OutputFormatTable, OutputFormatJSON)
+}
+
+func (b *Builder) Status(ctx context.Context, groupName string) (*Status, error) {
+  c, err := b.newClient(ctx, &RunConfig{GroupName: groupName})
+  if err != nil {
+    return nil, err
+  }
+  return c.GetStatus(ctx, groupName)
+}
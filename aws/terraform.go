@@ -0,0 +1,496 @@
+package aws
+
+import (
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+var terraformNameRegExp = regexp.MustCompile("[^a-zA-Z0-9_-]")
+
+type hclReference string
+
+type hclWriter struct {
+  builder strings.Builder
+  indent  int
+}
+
+func quoteHCLString(value string) string {
+  value = strings.ReplaceAll(value, "${", "$${")
+  value = strings.ReplaceAll(value, "%{", "%%{")
+  return strconv.Quote(value)
+}
+
+func formatHCLValue(value interface{}) string {
+  switch v := value.(type) {
+  case hclReference:
+    return string(v)
+  case string:
+    return quoteHCLString(v)
+  case []string:
+    var quoted []string
+    for _, s := range v {
+      quoted = append(quoted, quoteHCLString(s))
+    }
+    return "[" + strings.Join(quoted, ", ") + "]"
+  case []hclReference:
+    var references []string
+    for _, reference := range v {
+      references = append(references, string(reference))
+    }
+    return "[" + strings.Join(references, ", ") + "]"
+  case []interface{}:
+    var values []string
+    for _, item := range v {
+      values = append(values, formatHCLValue(item))
+    }
+    return "[" + strings.Join(values, ", ") + "]"
+  }
+  return fmt.Sprint(value)
+}
+
+func (w *hclWriter) line(format string, args ...interface{}) {
+  w.builder.WriteString(strings.Repeat("  ", w.indent))
+  w.builder.WriteString(fmt.Sprintf(format, args...))
+  w.builder.WriteString("\n")
+}
+
+func (w *hclWriter) openBlock(header string) {
+  w.line("%s {", header)
+  w.indent++
+}
+
+func (w *hclWriter) closeBlock() {
+  w.indent--
+  w.line("}")
+}
+
+func (w *hclWriter) attribute(name string, value interface{}) {
+  w.line("%s = %s", name, formatHCLValue(value))
+}
+
+func (w *hclWriter) stringAttribute(name string, value *string) {
+  if value != nil && *value != "" {
+    w.attribute(name, *value)
+  }
+}
+
+func (w *hclWriter) int32Attribute(name string, value *int32) {
+  if value != nil {
+    w.attribute(name, *value)
+  }
+}
+
+func (w *hclWriter) boolAttribute(name string, value *bool) {
+  if value != nil {
+    w.attribute(name, *value)
+  }
+}
+
+func (w *hclWriter) listAttribute(name string, values []string) {
+  if len(values) != 0 {
+    w.attribute(name, values)
+  }
+}
+
+type terraformImport struct {
+  address string
+  id      string
+}
+
+type terraformExporter struct {
+  stack   *Stack
+  writer  hclWriter
+  imports []terraformImport
+  names   map[string]bool
+}
+
+func makeTerraformName(name string) string {
+  name = terraformNameRegExp.ReplaceAllString(name, "_")
+  if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+    name = "r_" + name
+  }
+  return name
+}
+
+func (e *terraformExporter) openResource(resourceType string, name string, id string) string {
+  name = makeTerraformName(name)
+  address := resourceType + "." + name
+  for i := 2; e.names[address]; i++ {
+    address = fmt.Sprintf("%s.%s_%d", resourceType, name, i)
+  }
+  e.names[address] = true
+  if id != "" {
+    e.imports = append(e.imports, terraformImport{address: address, id: id})
+  }
+  if e.writer.builder.Len() != 0 {
+    e.writer.line("")
+  }
+  e.writer.openBlock(fmt.Sprintf("resource %q %q", resourceType, strings.TrimPrefix(address, resourceType+".")))
+  return address
+}
+
+func (e *terraformExporter) getTargetGroupReference(targetGroupARN string, addresses map[string]string) interface{} {
+  if address, ok := addresses[targetGroupARN]; ok {
+    return hclReference(address + ".arn")
+  }
+  return targetGroupARN
+}
+
+func (e *terraformExporter) writeAction(blockName string, action elbtypes.Action, targetGroupAddresses map[string]string) {
+  e.writer.openBlock(blockName)
+  e.writer.attribute("type", string(action.Type))
+  e.writer.int32Attribute("order", action.Order)
+  switch {
+  case action.Type == elbtypes.ActionTypeEnumForward && action.TargetGroupArn != nil:
+    e.writer.attribute("target_group_arn", e.getTargetGroupReference(*action.TargetGroupArn, targetGroupAddresses))
+  case action.Type == elbtypes.ActionTypeEnumForward && action.ForwardConfig != nil:
+    e.writer.openBlock("forward")
+    for _, targetGroup := range action.ForwardConfig.TargetGroups {
+      e.writer.openBlock("target_group")
+      e.writer.attribute("arn", e.getTargetGroupReference(*targetGroup.TargetGroupArn, targetGroupAddresses))
+      e.writer.int32Attribute("weight", targetGroup.Weight)
+      e.writer.closeBlock()
+    }
+    e.writer.closeBlock()
+  case action.Type == elbtypes.ActionTypeEnumRedirect && action.RedirectConfig != nil:
+    e.writer.openBlock("redirect")
+    e.writer.attribute("status_code", string(action.RedirectConfig.StatusCode))
+    e.writer.stringAttribute("host", action.RedirectConfig.Host)
+    e.writer.stringAttribute("path", action.RedirectConfig.Path)
+    e.writer.stringAttribute("port", action.RedirectConfig.Port)
+    e.writer.stringAttribute("protocol", action.RedirectConfig.Protocol)
+    e.writer.stringAttribute("query", action.RedirectConfig.Query)
+    e.writer.closeBlock()
+  case action.Type == elbtypes.ActionTypeEnumFixedResponse && action.FixedResponseConfig != nil:
+    e.writer.openBlock("fixed_response")
+    e.writer.stringAttribute("content_type", action.FixedResponseConfig.ContentType)
+    e.writer.stringAttribute("message_body", action.FixedResponseConfig.MessageBody)
+    e.writer.stringAttribute("status_code", action.FixedResponseConfig.StatusCode)
+    e.writer.closeBlock()
+  default:
+    e.writer.line("# the %q action configuration is not exported, please add it manually", action.Type)
+  }
+  e.writer.closeBlock()
+}
+
+func (e *terraformExporter) writeLaunchTemplate() string {
+  version := e.stack.LaunchTemplateVersion
+  if version == nil {
+    return ""
+  }
+  data := e.stack.GetLaunchTemplateData()
+  address := e.openResource("aws_launch_template", *version.LaunchTemplateName, *version.LaunchTemplateId)
+  e.writer.attribute("name", *version.LaunchTemplateName)
+  e.writer.stringAttribute("image_id", data.ImageId)
+  if data.InstanceType != "" {
+    e.writer.attribute("instance_type", string(data.InstanceType))
+  }
+  e.writer.stringAttribute("key_name", data.KeyName)
+  e.writer.stringAttribute("kernel_id", data.KernelId)
+  e.writer.listAttribute("vpc_security_group_ids", data.SecurityGroupIds)
+  e.writer.stringAttribute("user_data", data.UserData)
+  if data.IamInstanceProfile != nil {
+    e.writer.openBlock("iam_instance_profile")
+    e.writer.stringAttribute("arn", data.IamInstanceProfile.Arn)
+    e.writer.stringAttribute("name", data.IamInstanceProfile.Name)
+    e.writer.closeBlock()
+  }
+  if data.InstanceMarketOptions != nil && data.InstanceMarketOptions.MarketType != "" {
+    e.writer.openBlock("instance_market_options")
+    e.writer.attribute("market_type", string(data.InstanceMarketOptions.MarketType))
+    e.writer.closeBlock()
+  }
+  if data.Placement != nil && (data.Placement.Tenancy != "" || data.Placement.GroupName != nil) {
+    e.writer.openBlock("placement")
+    if data.Placement.Tenancy != "" {
+      e.writer.attribute("tenancy", string(data.Placement.Tenancy))
+    }
+    e.writer.stringAttribute("group_name", data.Placement.GroupName)
+    e.writer.closeBlock()
+  }
+  for _, license := range data.LicenseSpecifications {
+    e.writer.openBlock("license_specification")
+    e.writer.stringAttribute("license_configuration_arn", license.LicenseConfigurationArn)
+    e.writer.closeBlock()
+  }
+  e.writer.closeBlock()
+  return address
+}
+
+func (e *terraformExporter) writeTargetGroups() map[string]string {
+  addresses := map[string]string{}
+  for _, targetGroup := range e.stack.TargetGroups {
+    address := e.openResource("aws_lb_target_group", *targetGroup.TargetGroupName, *targetGroup.TargetGroupArn)
+    addresses[*targetGroup.TargetGroupArn] = address
+    e.writer.attribute("name", *targetGroup.TargetGroupName)
+    e.writer.int32Attribute("port", targetGroup.Port)
+    if targetGroup.Protocol != "" {
+      e.writer.attribute("protocol", string(targetGroup.Protocol))
+    }
+    e.writer.stringAttribute("vpc_id", targetGroup.VpcId)
+    if targetGroup.TargetType != "" {
+      e.writer.attribute("target_type", string(targetGroup.TargetType))
+    }
+    e.writer.openBlock("health_check")
+    e.writer.boolAttribute("enabled", targetGroup.HealthCheckEnabled)
+    e.writer.stringAttribute("path", targetGroup.HealthCheckPath)
+    e.writer.stringAttribute("port", targetGroup.HealthCheckPort)
+    if targetGroup.HealthCheckProtocol != "" {
+      e.writer.attribute("protocol", string(targetGroup.HealthCheckProtocol))
+    }
+    if targetGroup.Matcher != nil {
+      e.writer.stringAttribute("matcher", targetGroup.Matcher.HttpCode)
+    }
+    e.writer.int32Attribute("interval", targetGroup.HealthCheckIntervalSeconds)
+    e.writer.int32Attribute("timeout", targetGroup.HealthCheckTimeoutSeconds)
+    e.writer.int32Attribute("healthy_threshold", targetGroup.HealthyThresholdCount)
+    e.writer.int32Attribute("unhealthy_threshold", targetGroup.UnhealthyThresholdCount)
+    e.writer.closeBlock()
+    e.writer.closeBlock()
+  }
+  return addresses
+}
+
+func (e *terraformExporter) writeLoadBalancerAttributes(attributes map[string]string) {
+  if len(attributes) == 0 {
+    return
+  }
+  boolAttributes := []struct {
+    name string
+    key  string
+  }{
+    {"enable_deletion_protection", attributeDeletionProtection},
+    {"enable_http2", attributeHTTP2},
+    {"drop_invalid_header_fields", attributeDropInvalidHeaders},
+  }
+  for _, attribute := range boolAttributes {
+    if value, ok := attributes[attribute.key]; ok {
+      e.writer.attribute(attribute.name, value == "true")
+    }
+  }
+  if value, err := strconv.Atoi(attributes[attributeIdleTimeout]); err == nil {
+    e.writer.attribute("idle_timeout", value)
+  }
+  if value := attributes[attributeDesyncMitigationMode]; value != "" {
+    e.writer.attribute("desync_mitigation_mode", value)
+  }
+  if bucket := attributes[attributeAccessLogsBucket]; bucket != "" {
+    e.writer.openBlock("access_logs")
+    e.writer.attribute("bucket", bucket)
+    if prefix := attributes[attributeAccessLogsPrefix]; prefix != "" {
+      e.writer.attribute("prefix", prefix)
+    }
+    e.writer.attribute("enabled", attributes[attributeAccessLogsEnabled] == "true")
+    e.writer.closeBlock()
+  }
+}
+
+func (e *terraformExporter) writeLoadBalancers(targetGroupAddresses map[string]string) map[string]string {
+  addresses := map[string]string{}
+  for _, loadBalancer := range e.stack.LoadBalancers {
+    address := e.openResource("aws_lb", *loadBalancer.LoadBalancerName, *loadBalancer.LoadBalancerArn)
+    addresses[*loadBalancer.LoadBalancerArn] = address
+    e.writer.attribute("name", *loadBalancer.LoadBalancerName)
+    e.writer.attribute("internal", loadBalancer.Scheme == elbtypes.LoadBalancerSchemeEnumInternal)
+    e.writer.attribute("load_balancer_type", string(loadBalancer.Type))
+    var subnetIDs []string
+    for _, zone := range loadBalancer.AvailabilityZones {
+      if zone.SubnetId != nil {
+        subnetIDs = append(subnetIDs, *zone.SubnetId)
+      }
+    }
+    e.writer.listAttribute("subnets", subnetIDs)
+    e.writer.listAttribute("security_groups", loadBalancer.SecurityGroups)
+    if loadBalancer.IpAddressType != "" {
+      e.writer.attribute("ip_address_type", string(loadBalancer.IpAddressType))
+    }
+    e.writeLoadBalancerAttributes(e.stack.LoadBalancerAttributes[*loadBalancer.LoadBalancerArn])
+    e.writer.closeBlock()
+  }
+  listenerAddresses := map[string]string{}
+  for _, listener := range e.stack.Listeners {
+    name := fmt.Sprintf("%s_%d", strings.TrimPrefix(addresses[*listener.LoadBalancerArn], "aws_lb."), *listener.Port)
+    address := e.openResource("aws_lb_listener", name, *listener.ListenerArn)
+    listenerAddresses[*listener.ListenerArn] = address
+    e.writer.attribute("load_balancer_arn", hclReference(addresses[*listener.LoadBalancerArn]+".arn"))
+    e.writer.int32Attribute("port", listener.Port)
+    e.writer.attribute("protocol", string(listener.Protocol))
+    e.writer.stringAttribute("ssl_policy", listener.SslPolicy)
+    for _, certificate := range listener.Certificates {
+      if certificate.IsDefault == nil || *certificate.IsDefault {
+        e.writer.stringAttribute("certificate_arn", certificate.CertificateArn)
+        break
+      }
+    }
+    for _, action := range listener.DefaultActions {
+      e.writeAction("default_action", action, targetGroupAddresses)
+    }
+    e.writer.closeBlock()
+  }
+  return listenerAddresses
+}
+
+func (e *terraformExporter) writeListenerRules(listenerAddresses map[string]string, targetGroupAddresses map[string]string) {
+  for _, listenerRule := range e.stack.ListenerRules {
+    rule := listenerRule.Rule
+    name := *rule.Priority
+    for _, targetGroupARN := range getForwardTargetGroupARNs(rule.Actions) {
+      if address, ok := targetGroupAddresses[targetGroupARN]; ok {
+        name = fmt.Sprintf("%s_%s", strings.TrimPrefix(address, "aws_lb_target_group."), *rule.Priority)
+        break
+      }
+    }
+    e.openResource("aws_lb_listener_rule", name, *rule.RuleArn)
+    if address, ok := listenerAddresses[listenerRule.ListenerARN]; ok {
+      e.writer.attribute("listener_arn", hclReference(address+".arn"))
+    } else {
+      e.writer.attribute("listener_arn", listenerRule.ListenerARN)
+    }
+    priority, err := strconv.Atoi(*rule.Priority)
+    if err == nil {
+      e.writer.attribute("priority", priority)
+    }
+    for _, action := range rule.Actions {
+      e.writeAction("action", action, targetGroupAddresses)
+    }
+    for _, condition := range rule.Conditions {
+      e.writer.openBlock("condition")
+      switch {
+      case condition.HostHeaderConfig != nil:
+        e.writer.openBlock("host_header")
+        e.writer.attribute("values", condition.HostHeaderConfig.Values)
+        e.writer.closeBlock()
+      case condition.PathPatternConfig != nil:
+        e.writer.openBlock("path_pattern")
+        e.writer.attribute("values", condition.PathPatternConfig.Values)
+        e.writer.closeBlock()
+      default:
+        e.writer.line("# the %q condition is not exported, please add it manually", aws.ToString(condition.Field))
+      }
+      e.writer.closeBlock()
+    }
+    e.writer.closeBlock()
+  }
+}
+
+func (e *terraformExporter) writeAutoScalingGroup(launchTemplateAddress string, targetGroupAddresses map[string]string) string {
+  group := e.stack.Group
+  address := e.openResource("aws_autoscaling_group", *group.AutoScalingGroupName, *group.AutoScalingGroupName)
+  e.writer.attribute("name", *group.AutoScalingGroupName)
+  e.writer.int32Attribute("min_size", group.MinSize)
+  e.writer.int32Attribute("max_size", group.MaxSize)
+  e.writer.int32Attribute("desired_capacity", group.DesiredCapacity)
+  e.writer.boolAttribute("capacity_rebalance", group.CapacityRebalance)
+  e.writer.stringAttribute("health_check_type", group.HealthCheckType)
+  e.writer.int32Attribute("health_check_grace_period", group.HealthCheckGracePeriod)
+  e.writer.listAttribute("termination_policies", group.TerminationPolicies)
+  e.writer.boolAttribute("protect_from_scale_in", group.NewInstancesProtectedFromScaleIn)
+  e.writer.int32Attribute("max_instance_lifetime", group.MaxInstanceLifetime)
+  e.writer.int32Attribute("default_cooldown", group.DefaultCooldown)
+  if group.VPCZoneIdentifier != nil && *group.VPCZoneIdentifier != "" {
+    e.writer.attribute("vpc_zone_identifier", strings.Split(*group.VPCZoneIdentifier, ","))
+  }
+  var targetGroups []interface{}
+  for _, targetGroupARN := range group.TargetGroupARNs {
+    targetGroups = append(targetGroups, e.getTargetGroupReference(targetGroupARN, targetGroupAddresses))
+  }
+  if len(targetGroups) != 0 {
+    e.writer.attribute("target_group_arns", targetGroups)
+  }
+  var metrics []string
+  granularity := ""
+  for _, metric := range group.EnabledMetrics {
+    metrics = append(metrics, *metric.Metric)
+    granularity = aws.ToString(metric.Granularity)
+  }
+  if len(metrics) != 0 {
+    e.writer.attribute("enabled_metrics", metrics)
+    e.writer.attribute("metrics_granularity", granularity)
+  }
+  if launchTemplate := getGroupLaunchTemplate(group); launchTemplate != nil && launchTemplateAddress != "" {
+    e.writer.openBlock("launch_template")
+    e.writer.attribute("id", hclReference(launchTemplateAddress+".id"))
+    version := "$Default"
+    if launchTemplate.Version != nil {
+      version = *launchTemplate.Version
+    }
+    e.writer.attribute("version", version)
+    e.writer.closeBlock()
+  }
+  if warmPool := group.WarmPoolConfiguration; warmPool != nil {
+    e.writer.openBlock("warm_pool")
+    e.writer.attribute("pool_state", string(warmPool.PoolState))
+    e.writer.int32Attribute("min_size", warmPool.MinSize)
+    e.writer.int32Attribute("max_group_prepared_capacity", warmPool.MaxGroupPreparedCapacity)
+    if warmPool.InstanceReusePolicy != nil {
+      e.writer.openBlock("instance_reuse_policy")
+      e.writer.boolAttribute("reuse_on_scale_in", warmPool.InstanceReusePolicy.ReuseOnScaleIn)
+      e.writer.closeBlock()
+    }
+    e.writer.closeBlock()
+  }
+  for _, tag := range group.Tags {
+    e.writer.openBlock("tag")
+    e.writer.attribute("key", aws.ToString(tag.Key))
+    e.writer.attribute("value", aws.ToString(tag.Value))
+    e.writer.attribute("propagate_at_launch", aws.ToBool(tag.PropagateAtLaunch))
+    e.writer.closeBlock()
+  }
+  e.writer.closeBlock()
+  return address
+}
+
+func (e *terraformExporter) writeLifecycleHooks(groupAddress string) {
+  for _, hook := range e.stack.LifecycleHooks {
+    e.openResource("aws_autoscaling_lifecycle_hook", *hook.LifecycleHookName, *hook.AutoScalingGroupName+"/"+*hook.LifecycleHookName)
+    e.writer.attribute("name", *hook.LifecycleHookName)
+    e.writer.attribute("autoscaling_group_name", hclReference(groupAddress+".name"))
+    e.writer.stringAttribute("lifecycle_transition", hook.LifecycleTransition)
+    e.writer.stringAttribute("default_result", hook.DefaultResult)
+    e.writer.int32Attribute("heartbeat_timeout", hook.HeartbeatTimeout)
+    e.writer.stringAttribute("notification_target_arn", hook.NotificationTargetARN)
+    e.writer.stringAttribute("role_arn", hook.RoleARN)
+    e.writer.stringAttribute("notification_metadata", hook.NotificationMetadata)
+    e.writer.closeBlock()
+  }
+}
+
+func (e *terraformExporter) writeNotifications(groupAddress string) {
+  for _, topicARN := range e.stack.getNotificationTopicARNs() {
+    e.openResource("aws_autoscaling_notification", topicARN[strings.LastIndex(topicARN, ":")+1:], "")
+    e.writer.attribute("group_names", []hclReference{hclReference(groupAddress + ".name")})
+    e.writer.attribute("notifications", e.stack.getNotificationTypes(topicARN))
+    e.writer.attribute("topic_arn", topicARN)
+    e.writer.closeBlock()
+  }
+}
+
+func (e *terraformExporter) writeImports() {
+  for _, terraformImport := range e.imports {
+    e.writer.line("")
+    e.writer.openBlock("import")
+    e.writer.attribute("to", hclReference(terraformImport.address))
+    e.writer.attribute("id", terraformImport.id)
+    e.writer.closeBlock()
+  }
+}
+
+func ExportTerraform(stack *Stack) string {
+  e := &terraformExporter{
+    stack: stack,
+    names: map[string]bool{},
+  }
+  launchTemplateAddress := e.writeLaunchTemplate()
+  targetGroupAddresses := e.writeTargetGroups()
+  listenerAddresses := e.writeLoadBalancers(targetGroupAddresses)
+  e.writeListenerRules(listenerAddresses, targetGroupAddresses)
+  groupAddress := e.writeAutoScalingGroup(launchTemplateAddress, targetGroupAddresses)
+  e.writeLifecycleHooks(groupAddress)
+  e.writeNotifications(groupAddress)
+  e.writeImports()
+  return e.writer.builder.String()
+}
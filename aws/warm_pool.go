@@ -0,0 +1,111 @@
+package aws
+
+import (
+  "context"
+  "fmt"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling"
+  "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+  "strings"
+)
+
+const defaultWarmPoolMaxPreparedCapacity = -1
+
+type WarmPool struct {
+  MinSize             int32
+  MaxPreparedCapacity int32
+  PoolState           types.WarmPoolState
+  ReuseOnScaleIn      bool
+}
+
+func ParseWarmPoolState(state string) (types.WarmPoolState, error) {
+  var known []string
+  for _, value := range types.WarmPoolState("").Values() {
+    if strings.EqualFold(state, string(value)) {
+      return value, nil
+    }
+    known = append(known, string(value))
+  }
+  return "", fmt.Errorf("unknown warm pool state %q, expected one of %s", state, strings.Join(known, ", "))
+}
+
+func NewWarmPool(minSize int, maxPreparedCapacity int, state string, reuseOnScaleIn bool) (*WarmPool, error) {
+  if minSize < 0 {
+    return nil, fmt.Errorf("the warm pool min size must not be negative, got %d", minSize)
+  }
+  if maxPreparedCapacity < defaultWarmPoolMaxPreparedCapacity {
+    return nil, fmt.Errorf("the warm pool max prepared capacity must not be negative, got %d", maxPreparedCapacity)
+  }
+  poolState, err := ParseWarmPoolState(state)
+  if err != nil {
+    return nil, err
+  }
+  return &WarmPool{
+    MinSize:             int32(minSize),
+    MaxPreparedCapacity: int32(maxPreparedCapacity),
+    PoolState:           poolState,
+    ReuseOnScaleIn:      reuseOnScaleIn,
+  }, nil
+}
+
+func (c *RunConfig) HasWarmPool() bool {
+  return c.WarmPool != nil
+}
+
+func (c *Client) PutWarmPool(ctx context.Context) error {
+  warmPool := c.rc.WarmPool
+  err := c.withRetries(ctx, "put a warm pool", func() error {
+    _, err := c.autoscalingClient.PutWarmPool(ctx, &autoscaling.PutWarmPoolInput{
+      AutoScalingGroupName:     aws.String(c.rc.GetGroupName()),
+      MinSize:                  aws.Int32(warmPool.MinSize),
+      MaxGroupPreparedCapacity: aws.Int32(warmPool.MaxPreparedCapacity),
+      PoolState:                warmPool.PoolState,
+      InstanceReusePolicy: &types.InstanceReusePolicy{
+        ReuseOnScaleIn: aws.Bool(warmPool.ReuseOnScaleIn),
+      },
+    }, withoutAutoScalingRetries)
+    return err
+  })
+  if err != nil {
+    return fmt.Errorf("cannot put the warm pool of the group %q: %w", c.rc.GetGroupName(), err)
+  }
+  c.emit(&Event{
+    Kind:         EventKindResourceState,
+    Step:         stepWarmPool,
+    ResourceType: resourceWarmPool,
+    ResourceID:   c.rc.GetGroupName(),
+    State:        "created",
+    Message:      fmt.Sprintf("warm pool of the group %q keeps at least %d %s instances", c.rc.GetGroupName(), warmPool.MinSize, strings.ToLower(string(warmPool.PoolState))),
+  })
+  return nil
+}
+
+func (c *Client) deleteWarmPool(ctx context.Context, groupName string) error {
+  return c.withRetries(ctx, "delete the warm pool", func() error {
+    _, err := c.autoscalingClient.DeleteWarmPool(ctx, &autoscaling.DeleteWarmPoolInput{
+      AutoScalingGroupName: aws.String(groupName),
+      ForceDelete:          aws.Bool(true),
+    }, withoutAutoScalingRetries)
+    return err
+  })
+}
+
+func (c *Client) describeWarmPoolInstances(ctx context.Context, groupName string) ([]types.Instance, error) {
+  var instances []types.Instance
+  var nextToken *string
+  for {
+    res, err := c.autoscalingClient.DescribeWarmPool(ctx, &autoscaling.DescribeWarmPoolInput{
+      AutoScalingGroupName: aws.String(groupName),
+      NextToken:            nextToken,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("cannot describe the warm pool of the group %q: %w", groupName, err)
+    }
+    instances = append(instances, res.Instances...)
+    nextToken = res.NextToken
+    if nextToken == nil {
+      break
+    }
+  }
+  return instances, nil
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log"
+  "net/http"
+  "sync"
+  "time"
+)
+
+const (
+  webhookTimeout   = 10 * time.Second
+  webhookQueueSize = 100
+)
+
+type webhookMessage struct {
+  Text   string  `json:"text"`
+  Source string  `json:"source,omitempty"`
+  Event  *Event  `json:"event,omitempty"`
+  Report *Report `json:"report,omitempty"`
+}
+
+type WebhookSink struct {
+  url    string
+  source string
+  client *http.Client
+  mutex  sync.Mutex
+  queue  chan *webhookMessage
+  done   chan struct{}
+}
+
+func NewWebhookSink(url string, source string) *WebhookSink {
+  return &WebhookSink{
+    url:    url,
+    source: source,
+    client: &http.Client{Timeout: webhookTimeout},
+  }
+}
+
+func (s *WebhookSink) post(ctx context.Context, message *webhookMessage) error {
+  message.Source = s.source
+  if s.source != "" {
+    message.Text = fmt.Sprintf("[%s] %s", s.source, message.Text)
+  }
+  body, err := json.Marshal(message)
+  if err != nil {
+    return fmt.Errorf("cannot marshal the webhook message: %w", err)
+  }
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+  if err != nil {
+    return fmt.Errorf("cannot create the webhook request to %s: %w", s.url, err)
+  }
+  req.Header.Set("Content-Type", "application/json")
+  res, err := s.client.Do(req)
+  if err != nil {
+    return fmt.Errorf("cannot post to the webhook %s: %w", s.url, err)
+  }
+  defer res.Body.Close()
+  io.Copy(io.Discard, res.Body)
+  if res.StatusCode < 200 || res.StatusCode >= 300 {
+    return fmt.Errorf("the webhook %s responded with %s", s.url, res.Status)
+  }
+  return nil
+}
+
+func (s *WebhookSink) start() {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  if s.queue != nil {
+    return
+  }
+  s.queue = make(chan *webhookMessage, webhookQueueSize)
+  s.done = make(chan struct{})
+  go s.run(s.queue, s.done)
+}
+
+func (s *WebhookSink) postDetached(message *webhookMessage) error {
+  ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+  defer cancel()
+  return s.post(ctx, message)
+}
+
+func (s *WebhookSink) run(queue <-chan *webhookMessage, done chan<- struct{}) {
+  defer close(done)
+  for message := range queue {
+    if err := s.postDetached(message); err != nil {
+      log.Println(err)
+    }
+  }
+}
+
+func (s *WebhookSink) flush() {
+  s.mutex.Lock()
+  queue, done := s.queue, s.done
+  s.queue, s.done = nil, nil
+  s.mutex.Unlock()
+  if queue == nil {
+    return
+  }
+  close(queue)
+  <-done
+}
+
+func (s *WebhookSink) HandleEvent(event Event) {
+  switch event.Kind {
+  case EventKindStepStarted, EventKindStepFinished, EventKindStepFailed, EventKindCleanup:
+  default:
+    return
+  }
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  if s.queue == nil {
+    log.Printf("the webhook %s is not started, dropping the event: %s", s.url, event.Message)
+    return
+  }
+  select {
+  case s.queue <- &webhookMessage{Text: event.Message, Event: &event}:
+  default:
+    log.Printf("the webhook %s queue is full, dropping the event: %s", s.url, event.Message)
+  }
+}
+
+func (s *WebhookSink) Send(ctx context.Context, text string) error {
+  return s.post(ctx, &webhookMessage{Text: text})
+}
+
+func (r *Report) getSummary() string {
+  if !r.Success {
+    return fmt.Sprintf("failed to build the auto scaling group %q in %.0fs: %s", r.GroupName, r.TotalSeconds, r.Error)
+  }
+  summary := fmt.Sprintf("built the auto scaling group %q in %.0fs", r.GroupName, r.TotalSeconds)
+  if r.HealthURL != "" {
+    summary += ", health URL: " + r.HealthURL
+  }
+  return summary
+}
+
+func (s *WebhookSink) SendReport(ctx context.Context, report *Report) error {
+  s.flush()
+  return s.post(ctx, &webhookMessage{Text: report.getSummary(), Report: report})
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+  "context"
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "sync"
+  "testing"
+)
+
+type webhookRecorder struct {
+  mutex    sync.Mutex
+  messages []map[string]interface{}
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+  var message map[string]interface{}
+  if err := json.NewDecoder(req.Body).Decode(&message); err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  r.mutex.Lock()
+  r.messages = append(r.messages, message)
+  r.mutex.Unlock()
+}
+
+func TestWebhookSinkPostsEventsAndReport(t *testing.T) {
+  recorder := &webhookRecorder{}
+  server := httptest.NewServer(recorder)
+  defer server.Close()
+
+  ctx := context.Background()
+  sink := NewWebhookSink(server.URL, "my_group")
+  sink.start()
+  sink.HandleEvent(Event{Kind: EventKindStepStarted, Step: stepAMI, Message: "creating the AMI"})
+  sink.HandleEvent(Event{Kind: EventKindResourceState, Message: "not posted"})
+  report := &Report{Success: true, GroupName: "my_group", TotalSeconds: 42, HealthURL: "http://example.com/health"}
+  if err := sink.SendReport(ctx, report); err != nil {
+    t.Fatalf("cannot send the report: %v", err)
+  }
+
+  if len(recorder.messages) != 2 {
+    t.Fatalf("expected 2 messages, got %d: %v", len(recorder.messages), recorder.messages)
+  }
+  expected := []string{
+    "[my_group] creating the AMI",
+    `[my_group] built the auto scaling group "my_group" in 42s, health URL: http://example.com/health`,
+  }
+  for i, text := range expected {
+    if recorder.messages[i]["text"] != text {
+      t.Errorf("expected the message %d text %q, got %q", i, text, recorder.messages[i]["text"])
+    }
+    if recorder.messages[i]["source"] != "my_group" {
+      t.Errorf("expected the message %d source %q, got %q", i, "my_group", recorder.messages[i]["source"])
+    }
+  }
+  if _, ok := recorder.messages[0]["event"]; !ok {
+    t.Errorf("expected the event message to carry the event")
+  }
+  if _, ok := recorder.messages[1]["report"]; !ok {
+    t.Errorf("expected the report message to carry the report")
+  }
+}
+
+func TestWebhookSinkReportsFailedStatus(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    w.WriteHeader(http.StatusInternalServerError)
+  }))
+  defer server.Close()
+
+  sink := NewWebhookSink(server.URL, "")
+  if err := sink.Send(context.Background(), "hello"); err == nil {
+    t.Fatalf("expected an error for the failed webhook response")
+  }
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+  "context"
+  "flag"
+  "fmt"
+  "github.com/ashagraev/aws_asg_builder/aws"
+  "log"
+  "os"
+  "sort"
+  "strings"
+  "time"
+)
+
+const diffDriftExitCode = 2
+
+var commands = map[string]func(args []string){
+  "apply":              runApply,
+  "bluegreen":          runBlueGreen,
+  "canary":             runCanary,
+  "complete-lifecycle": runCompleteLifecycle,
+  "diff":               runDiff,
+  "export":             runExport,
+  "list":               runList,
+  "notify":             runNotify,
+  "rollback":           runRollback,
+  "status":             runStatus,
+}
+
+func getCommandNames() string {
+  var names []string
+  for name := range commands {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return strings.Join(names, ", ")
+}
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+  return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+  *f = append(*f, value)
+  return nil
+}
+
+func runCommand(name string, args []string) {
+  command, ok := commands[name]
+  if !ok {
+    log.Fatalf("unknown command %q, expected one of: %s", name, getCommandNames())
+  }
+  command(args)
+}
+
+func writeOutput(outputPath string, content string) {
+  if outputPath == "" {
+    fmt.Print(content)
+    return
+  }
+  if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+    log.Fatalf("cannot write the output to %s: %v", outputPath, err)
+  }
+}
+
+func runExport(args []string) {
+  flags := flag.NewFlagSet("export", flag.ExitOnError)
+  groupName := flags.String("group", "", "the name of the Auto Scaling group to export; required.")
+  format := flags.String("format", string(aws.ExportFormatTerraform), "the export format; only terraform is supported.")
+  outputPath := flags.String("output", "", "the path to write the exported configuration to; optional, default: the standard output.")
+  flags.Parse(args)
+  if *groupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  exported, err := aws.NewBuilder().Export(context.Background(), *groupName, aws.ExportFormat(*format))
+  if err != nil {
+    log.Fatalln(err)
+  }
+  writeOutput(*outputPath, exported)
+}
+
+func runStatus(args []string) {
+  flags := flag.NewFlagSet("status", flag.ExitOnError)
+  groupName := flags.String("group", "", "the name of the Auto Scaling group to inspect; required.")
+  format := flags.String("format", string(aws.OutputFormatTable), "the output format: table or json.")
+  flags.Parse(args)
+  if *groupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  outputFormat := aws.OutputFormat(*format)
+  if outputFormat != aws.OutputFormatTable && outputFormat != aws.OutputFormatJSON {
+    log.Fatalf("unknown output format %q, expected %q or %q", outputFormat, aws.OutputFormatTable, aws.OutputFormatJSON)
+  }
+  status, err := aws.NewBuilder().Status(context.Background(), *groupName)
+  if err != nil {
+    log.Fatalln(err)
+  }
+  if err := status.Write(os.Stdout, outputFormat); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runList(args []string) {
+  flags := flag.NewFlagSet("list", flag.ExitOnError)
+  regions := flags.String("regions", "", "the comma-separated list of regions to search in; optional, default: the configured region.")
+  var tags stringsFlag
+  flags.Var(&tags, "tag", "list only the groups with the tag, in the KEY=VALUE or KEY format; optional, can be repeated.")
+  format := flags.String("format", string(aws.OutputFormatTable), "the output format: table, json or csv.")
+  flags.Parse(args)
+  outputFormat := aws.OutputFormat(*format)
+  if outputFormat != aws.OutputFormatTable && outputFormat != aws.OutputFormatJSON && outputFormat != aws.OutputFormatCSV {
+    log.Fatalf("unknown output format %q, expected %q, %q or %q", outputFormat, aws.OutputFormatTable, aws.OutputFormatJSON, aws.OutputFormatCSV)
+  }
+  options := aws.ListOptions{Tags: map[string]string{}}
+  if *regions != "" {
+    options.Regions = strings.Split(*regions, ",")
+  }
+  for _, tag := range tags {
+    key, value := aws.ParseTagFilter(tag)
+    options.Tags[key] = value
+  }
+  summaries, err := aws.NewBuilder().List(context.Background(), options)
+  if err != nil {
+    log.Fatalln(err)
+  }
+  if err := aws.WriteGroupSummaries(os.Stdout, summaries, outputFormat); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runDiff(args []string) {
+  flags := flag.NewFlagSet("diff", flag.ExitOnError)
+  format := flags.String("format", string(aws.OutputFormatTable), "the output format of the drifted fields: table or json.")
+  rc := initRunConfig(flags, args)
+  outputFormat := aws.OutputFormat(*format)
+  if outputFormat != aws.OutputFormatTable && outputFormat != aws.OutputFormatJSON {
+    log.Fatalf("unknown output format %q, expected %q or %q", outputFormat, aws.OutputFormatTable, aws.OutputFormatJSON)
+  }
+  if rc.GroupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  drifts, err := aws.NewBuilder().Diff(context.Background(), rc)
+  if err != nil {
+    log.Fatalln(err)
+  }
+  if err := aws.WriteDrifts(os.Stdout, drifts, outputFormat); err != nil {
+    log.Fatalln(err)
+  }
+  if len(drifts) != 0 {
+    log.Printf("found %d drifted fields of the group %q", len(drifts), rc.GetGroupName())
+    os.Exit(diffDriftExitCode)
+  }
+  log.Printf("the group %q matches the desired state", rc.GetGroupName())
+}
+
+func runApply(args []string) {
+  flags := flag.NewFlagSet("apply", flag.ExitOnError)
+  format := flags.String("format", string(aws.OutputFormatTable), "the output format of the applied drifted fields: table or json.")
+  rc := initRunConfig(flags, args)
+  outputFormat := aws.OutputFormat(*format)
+  if outputFormat != aws.OutputFormatTable && outputFormat != aws.OutputFormatJSON {
+    log.Fatalf("unknown output format %q, expected %q or %q", outputFormat, aws.OutputFormatTable, aws.OutputFormatJSON)
+  }
+  if rc.GroupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  drifts, applyErr := aws.NewBuilder().Apply(context.Background(), rc)
+  if err := aws.WriteDrifts(os.Stdout, drifts, outputFormat); err != nil {
+    log.Fatalln(err)
+  }
+  if applyErr != nil {
+    log.Fatalln(applyErr)
+  }
+  if len(drifts) == 0 {
+    log.Printf("the group %q already matches the desired state", rc.GetGroupName())
+    return
+  }
+  log.Printf("applied %d drifted fields of the group %q", len(drifts), rc.GetGroupName())
+}
+
+func runRollback(args []string) {
+  flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+  groupName := flags.String("group", "", "the name of the Auto Scaling group to roll back; required.")
+  toVersion := flags.Int64("to-version", 0, "the launch template version to roll back to; optional, default: the version before the current one. The groups built by the tool start with the launch template version 1, so there is nothing to roll back to until a newer version is created outside the tool.")
+  updateTimeoutStr := flags.String("update-timeout", "30m", "the time limit to complete the instance refresh. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  updateTickStr := flags.String("update-tick", "1m", "the time between status updates in the log file. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  flags.Parse(args)
+  if *groupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  updateTimeout, err := time.ParseDuration(*updateTimeoutStr)
+  if err != nil {
+    log.Fatalf("cannot parse the update timeout string: %v", err)
+  }
+  updateTick, err := time.ParseDuration(*updateTickStr)
+  if err != nil {
+    log.Fatalf("cannot parse the update tick string: %v", err)
+  }
+  if _, err := aws.NewBuilder().Rollback(context.Background(), *groupName, aws.RollbackOptions{
+    ToVersion:     *toVersion,
+    UpdateTimeout: updateTimeout,
+    UpdateTick:    updateTick,
+  }); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runCanary(args []string) {
+  flags := flag.NewFlagSet("canary", flag.ExitOnError)
+  canaryGroup := flags.String("canary-group", "", "the name of the new Auto Scaling group created from --instance next to --group; required.")
+  canaryStepsStr := flags.String("canary-steps", "5,25,100", "the comma-separated percentages of the traffic shifted to the new group step by step; the last one must be 100.")
+  canaryStepDurationStr := flags.String("canary-step-duration", "5m", "the time every step is observed before the next one. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  maxErrorRate := flags.Float64("canary-max-error-rate", 0.01, "the maximum share of the 5xx responses of the new group; exceeding it rolls the weights back.")
+  rc := initRunConfig(flags, args)
+  if rc.GroupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  canarySteps, err := aws.ParseCanarySteps(*canaryStepsStr)
+  if err != nil {
+    log.Fatalln(err)
+  }
+  canaryStepDuration, err := time.ParseDuration(*canaryStepDurationStr)
+  if err != nil {
+    log.Fatalf("cannot parse the canary step duration string: %v", err)
+  }
+  if err := aws.NewBuilder().Canary(context.Background(), rc, aws.CanaryOptions{
+    Group:        *canaryGroup,
+    Steps:        canarySteps,
+    StepDuration: canaryStepDuration,
+    MaxErrorRate: *maxErrorRate,
+  }); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runBlueGreen(args []string) {
+  flags := flag.NewFlagSet("bluegreen", flag.ExitOnError)
+  greenGroup := flags.String("green-group", "", "the name of the new Auto Scaling group created from --instance next to --group; required.")
+  bakeTimeStr := flags.String("bake-time", "30m", "the time the old group is kept after the switch; the traffic goes back to it if the new group fails within this time. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  maxErrorRate := flags.Float64("bake-max-error-rate", 0.01, "the maximum share of the 5xx responses of the new group during the bake time; exceeding it switches the traffic back.")
+  rc := initRunConfig(flags, args)
+  if rc.GroupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  bakeTime, err := time.ParseDuration(*bakeTimeStr)
+  if err != nil {
+    log.Fatalf("cannot parse the bake time string: %v", err)
+  }
+  if err := aws.NewBuilder().BlueGreen(context.Background(), rc, aws.BlueGreenOptions{
+    Group:        *greenGroup,
+    BakeTime:     bakeTime,
+    MaxErrorRate: *maxErrorRate,
+  }); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runCompleteLifecycle(args []string) {
+  flags := flag.NewFlagSet("complete-lifecycle", flag.ExitOnError)
+  groupName := flags.String("group", "", "the name of the Auto Scaling group the instance belongs to; required.")
+  transition := flags.String("transition", string(aws.LifecycleTransitionLaunch), "the transition of the hook created by the tool: launch or termination; ignored if --hook is set.")
+  hookName := flags.String("hook", "", "the name of the lifecycle hook; optional, default: the hook created by the tool for --transition.")
+  instanceID := flags.String("instance", "", "the ID of the instance; optional, default: the current instance from the instance metadata.")
+  token := flags.String("token", "", "the lifecycle action token received in the notification; optional.")
+  result := flags.String("result", aws.LifecycleResultContinue, "the lifecycle action result: CONTINUE or ABANDON.")
+  heartbeat := flags.Bool("heartbeat", false, "record a heartbeat extending the wait instead of completing the lifecycle action.")
+  flags.Parse(args)
+  if *groupName == "" {
+    log.Fatalln("the group name is required")
+  }
+  lifecycleTransition := aws.LifecycleTransition(*transition)
+  if lifecycleTransition != aws.LifecycleTransitionLaunch && lifecycleTransition != aws.LifecycleTransitionTermination {
+    log.Fatalf("unknown transition %q, expected %q or %q", lifecycleTransition, aws.LifecycleTransitionLaunch, aws.LifecycleTransitionTermination)
+  }
+  if *hookName == "" {
+    *hookName = *groupName + "-" + *transition
+  }
+  if err := aws.NewBuilder().CompleteLifecycleAction(context.Background(), aws.LifecycleAction{
+    GroupName:  *groupName,
+    HookName:   *hookName,
+    InstanceID: *instanceID,
+    Token:      *token,
+    Result:     *result,
+    Heartbeat:  *heartbeat,
+  }); err != nil {
+    log.Fatalln(err)
+  }
+}
+
+func runNotify(args []string) {
+  flags := flag.NewFlagSet("notify", flag.ExitOnError)
+  url := flags.String("url", "", "the webhook URL to post the message to; required.")
+  source := flags.String("source", "", "the name prefixing the message, e.g. the Auto Scaling group name; optional.")
+  message := flags.String("message", "test notification from aws_asg_builder", "the text of the message to post.")
+  flags.Parse(args)
+  if *url == "" {
+    log.Fatalln("the webhook URL is required")
+  }
+  if err := aws.NewWebhookSink(*url, *source).Send(context.Background(), *message); err != nil {
+    log.Fatalln(err)
+  }
+  log.Printf("posted the message to %s", *url)
+}
@@ -3,22 +3,93 @@ package main
 import (
   "context"
   "flag"
+  "fmt"
+  "github.com/ashagraev/aws_asg_builder/aws"
   "log"
-  "main/aws"
+  "os"
   "strings"
   "time"
 )
 
-func initRunConfig() *aws.RunConfig {
-  groupName := flag.String("group", "", "the name of the Auto Scaling group to create; required.")
-  instanceID := flag.String("instance", "", "AWS EC2 instance ID to create the service from; required.")
-  healthPath := flag.String("health-path", "/health", "the health HTTP handler for the service.")
-  daemonPort := flag.Int("port", 80, "the HTTP traffic port for the service.")
-  instancesCount := flag.Int("instances", 1, "the number of instances to create within the group; min instances count and desired instances count will be set up to this value, max instances count will be set up to twice this value.")
-  healthCheckGracePeriodStr := flag.String("health-check-grace-period", "1m", "the time needed for the instance to become healthy after the launch. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
-  updateTimeoutStr := flag.String("update-timeout", "30m", "the time limit to complete the instance refresh; optional, default: 30m. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
-  updateTickStr := flag.String("update-tick", "1m", "the time between status updates in the log file; optional, default: 1m. Making this parameter lower might speed up the overall execution. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
-  flag.Parse()
+type smokeChecksFlag []*aws.SmokeCheck
+
+func (f *smokeChecksFlag) String() string {
+  return fmt.Sprintf("%d checks", len(*f))
+}
+
+func (f *smokeChecksFlag) Set(value string) error {
+  check, err := aws.ParseSmokeCheck(value)
+  if err != nil {
+    return err
+  }
+  *f = append(*f, check)
+  return nil
+}
+
+func initRunConfig(flags *flag.FlagSet, args []string) *aws.RunConfig {
+  groupName := flags.String("group", "", "the name of the Auto Scaling group to create; required.")
+  instanceID := flags.String("instance", "", "AWS EC2 instance ID to create the service from; required.")
+  healthPath := flags.String("health-path", "/health", "the health HTTP handler for the service.")
+  daemonPort := flags.Int("port", 80, "the HTTP traffic port for the service.")
+  instancesCount := flags.Int("instances", 1, "the number of instances to create within the group; min instances count and desired instances count will be set up to this value, max instances count will be set up to twice this value.")
+  launchHook := flags.String("launch-hook", "", "the lifecycle hook holding the launched instances in the Pending:Wait state, in the HEARTBEAT_TIMEOUT[,DEFAULT_RESULT[,NOTIFICATION_TARGET_ARN,ROLE_ARN]] format, e.g. 10m,ABANDON; optional.")
+  terminationHook := flags.String("termination-hook", "", "the lifecycle hook holding the terminated instances in the Terminating:Wait state, in the HEARTBEAT_TIMEOUT[,DEFAULT_RESULT[,NOTIFICATION_TARGET_ARN,ROLE_ARN]] format, e.g. 5m,CONTINUE; optional.")
+  warmPool := flags.Bool("warm-pool", false, "keep a warm pool of pre-initialized instances next to the group to speed up the scale-out; optional.")
+  warmPoolMinSize := flags.Int("warm-pool-min-size", 0, "the minimum number of instances to keep in the warm pool.")
+  warmPoolMaxPrepared := flags.Int("warm-pool-max-prepared", -1, "the maximum number of instances allowed in the group and the warm pool together; optional, default: -1, meaning the group max size.")
+  warmPoolState := flags.String("warm-pool-state", "Stopped", "the state of the instances waiting in the warm pool: Stopped, Hibernated or Running.")
+  warmPoolReuse := flags.Bool("warm-pool-reuse", false, "return the instances to the warm pool on scale-in instead of terminating them; optional.")
+  notificationTopic := flags.String("notification-topic", "", "the ARN of the SNS topic to send the Auto Scaling group notifications to; optional.")
+  notificationTypesStr := flags.String("notification-types", aws.DefaultNotificationTypes(), "the comma-separated Auto Scaling group notifications to send to the SNS topic: launch, terminate, launch-error, terminate-error.")
+  notifyURL := flags.String("notify", "", "the webhook URL to post the build step events and the final report to as Slack-compatible JSON messages; optional.")
+  terminationPoliciesStr := flags.String("termination-policies", "", "the comma-separated termination policies of the group, e.g. OldestLaunchTemplate,OldestInstance; optional, default: Default.")
+  scaleInProtection := flags.Bool("scale-in-protection", false, "protect the new instances of the group from the scale-in; optional.")
+  maxInstanceLifetimeStr := flags.String("max-instance-lifetime", "0", "the maximum time an instance can serve in the group before being replaced, between 24h and 8760h; optional, default: 0, meaning no limit. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  defaultCooldownStr := flags.String("default-cooldown", aws.DefaultGroupCooldown.String(), "the time between the scaling activities of the simple scaling policies. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  healthCheckGracePeriodStr := flags.String("health-check-grace-period", "1m", "the time needed for the instance to become healthy after the launch. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  updateTimeoutStr := flags.String("update-timeout", "30m", "the time limit to complete the instance refresh; optional, default: 30m. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  updateTickStr := flags.String("update-tick", "1m", "the time between status updates in the log file; optional, default: 1m. Making this parameter lower might speed up the overall execution. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  existingBalancer := flags.String("existing-lb", "", "the name or ARN of an existing application load balancer to attach the service to instead of creating a new one; optional.")
+  defaultLoadBalancerAttributes := aws.DefaultLoadBalancerAttributes()
+  accessLogsBucket := flags.String("lb-access-logs-bucket", "", "the S3 bucket to write the load balancer access logs to; optional, by default the access logs are disabled.")
+  accessLogsPrefix := flags.String("lb-access-logs-prefix", "", "the prefix of the load balancer access logs in the S3 bucket, e.g. my_service; optional.")
+  deletionProtection := flags.Bool("lb-deletion-protection", false, "protect the load balancer from deletion; the cleanup lifts the protection before deleting the balancer; optional.")
+  idleTimeoutStr := flags.String("lb-idle-timeout", defaultLoadBalancerAttributes.IdleTimeout.String(), "the time the load balancer keeps an idle connection open, between 1s and 4000s. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  http2 := flags.Bool("lb-http2", defaultLoadBalancerAttributes.HTTP2, "enable HTTP/2 on the load balancer.")
+  dropInvalidHeaders := flags.Bool("lb-drop-invalid-headers", defaultLoadBalancerAttributes.DropInvalidHeaders, "drop the HTTP headers with invalid names on the load balancer; optional.")
+  desyncMitigationMode := flags.String("lb-desync-mitigation", defaultLoadBalancerAttributes.DesyncMitigationMode, "the HTTP desync mitigation mode of the load balancer: monitor, defensive or strictest.")
+  listenerPort := flags.Int("listener-port", 0, "the load balancer listener port; optional, default: the value of --port.")
+  hostHeader := flags.String("host-header", "", "the host header condition of the listener rule on the existing load balancer; optional.")
+  pathPattern := flags.String("path-pattern", "", "the path pattern condition of the listener rule on the existing load balancer; optional.")
+  dnsName := flags.String("dns-name", "", "the DNS name to point at the load balancer with an alias record, e.g. svc.example.com; optional, requires --hosted-zone.")
+  hostedZoneID := flags.String("hosted-zone", "", "the ID of the Route 53 hosted zone to create the DNS record in; optional, requires --dns-name.")
+  smokeTest := flags.Bool("smoke-test", false, "poll the service endpoint after the group is created until all the smoke checks pass several times in a row; optional.")
+  var smokeChecks smokeChecksFlag
+  flags.Var(&smokeChecks, "smoke-check", "an extra smoke check in the PATH[,STATUS[,BODY_REGEXP]] format, e.g. /version,200,^v[0-9]+; optional, can be repeated. The health path is always checked.")
+  smokeSuccesses := flags.Int("smoke-successes", 3, "the number of consecutive successful smoke test rounds required.")
+  smokeIntervalStr := flags.String("smoke-interval", "10s", "the time between smoke test rounds. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  smokeTimeoutStr := flags.String("smoke-timeout", "10m", "the time limit for the smoke test to pass. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  smokeCleanup := flags.Bool("smoke-cleanup", false, "delete all the created artifacts if the smoke test fails; optional.")
+  env := flags.String("env", "", "the environment name available as {{.Env}} in the naming templates, e.g. prod; optional.")
+  defaultNamingTemplates := aws.DefaultNamingTemplates()
+  groupNameTemplate := flags.String("group-name-template", defaultNamingTemplates.Group, "the Go template of the auto scaling group name.")
+  launchTemplateNameTemplate := flags.String("launch-template-name-template", defaultNamingTemplates.LaunchTemplate, "the Go template of the launch template name.")
+  targetGroupNameTemplate := flags.String("target-group-name-template", defaultNamingTemplates.TargetGroup, "the Go template of the target group name; underscores are replaced with hyphens, names longer than 32 symbols are truncated and suffixed with a short hash.")
+  balancerNameTemplate := flags.String("balancer-name-template", defaultNamingTemplates.Balancer, "the Go template of the load balancer name; underscores are replaced with hyphens, names longer than 32 symbols are truncated and suffixed with a short hash.")
+  amiNameTemplate := flags.String("ami-name-template", defaultNamingTemplates.AMI, "the Go template of the AMI name.")
+  defaultRetryPolicy := aws.DefaultRetryPolicy()
+  maxAttempts := flags.Int("max-attempts", defaultRetryPolicy.MaxAttempts, "the maximum number of attempts for every AWS API call creating an artifact; throttling, eventual consistency and server errors are retried, other errors fail the build immediately.")
+  retryBaseDelayStr := flags.String("retry-base-delay", defaultRetryPolicy.BaseDelay.String(), "the delay before the first retry; every next delay is twice longer, with a random jitter. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  retryMaxDelayStr := flags.String("retry-max-delay", defaultRetryPolicy.MaxDelay.String(), "the maximum delay between retries. Use the Golang duration strings to override, see https://pkg.go.dev/time#ParseDuration.")
+  logFormat := flags.String("log-format", string(aws.LogFormatText), "the log format: text or json; in the json mode every log line is a JSON object with the step, resource type, resource ID, state and elapsed time where applicable.")
+  emit := flags.String("emit", "", "instead of creating the artifacts, print a template describing them; the only supported value is cloudformation; optional.")
+  emitFormat := flags.String("emit-format", string(aws.TemplateFormatYAML), "the format of the emitted template: yaml or json.")
+  reportPath := flags.String("output", "", "the path to write the JSON report with all the created resources, console links, DNS names and step timings to; optional.")
+  flags.Parse(args)
+
+  if err := aws.SetLogFormat(aws.LogFormat(*logFormat)); err != nil {
+    log.Fatalln(err)
+  }
 
   updateTimeout, err := time.ParseDuration(*updateTimeoutStr)
   if err != nil {
@@ -32,64 +103,146 @@ func initRunConfig() *aws.RunConfig {
   if err != nil {
     log.Fatalf("cannot parse the health check grace period string: %v", err)
   }
-
-  return &aws.RunConfig{
-    InstanceID:             *instanceID,
-    GroupName:              *groupName,
-    HealthPath:             *healthPath,
-    DaemonPort:             int32(*daemonPort),
-    InstancesCount:         int32(*instancesCount),
-    HealthCheckGracePeriod: healthCheckGracePeriod,
-    UpdateTimeout:          updateTimeout,
-    UpdateTick:             updateTick,
+  maxInstanceLifetime, err := time.ParseDuration(*maxInstanceLifetimeStr)
+  if err != nil {
+    log.Fatalf("cannot parse the max instance lifetime string: %v", err)
   }
-}
-
-func FatalError(err error, client *aws.Client) {
-  client.Cleanup()
-  log.Fatalln(err)
-}
-
-func main() {
-  rc := initRunConfig()
-  if err := rc.ValidateArtifactNames(); err != nil {
-    log.Fatalln(err)
+  defaultCooldown, err := time.ParseDuration(*defaultCooldownStr)
+  if err != nil {
+    log.Fatalf("cannot parse the default cooldown string: %v", err)
   }
-  client, err := aws.NewClient(context.Background(), rc)
+  idleTimeout, err := time.ParseDuration(*idleTimeoutStr)
   if err != nil {
-    log.Fatalln(err)
+    log.Fatalf("cannot parse the load balancer idle timeout string: %v", err)
   }
-  instanceData, err := client.DescribeInstance()
+  smokeInterval, err := time.ParseDuration(*smokeIntervalStr)
   if err != nil {
-    log.Fatalln(err)
+    log.Fatalf("cannot parse the smoke test interval string: %v", err)
   }
-  defaultVPCID, err := client.GetDefaultVPCID()
+  smokeTimeout, err := time.ParseDuration(*smokeTimeoutStr)
   if err != nil {
-    log.Fatalln(err)
+    log.Fatalf("cannot parse the smoke test timeout string: %v", err)
   }
-  subnetIDs, err := client.GetSubnets(defaultVPCID)
+  retryBaseDelay, err := time.ParseDuration(*retryBaseDelayStr)
+  if err != nil {
+    log.Fatalf("cannot parse the retry base delay string: %v", err)
+  }
+  retryMaxDelay, err := time.ParseDuration(*retryMaxDelayStr)
+  if err != nil {
+    log.Fatalf("cannot parse the retry max delay string: %v", err)
+  }
+
+  var lifecycleHooks []*aws.LifecycleHook
+  for _, h := range []struct {
+    transition  aws.LifecycleTransition
+    description string
+  }{
+    {aws.LifecycleTransitionLaunch, *launchHook},
+    {aws.LifecycleTransitionTermination, *terminationHook},
+  } {
+    if h.description == "" {
+      continue
+    }
+    hook, err := aws.ParseLifecycleHook(h.transition, h.description)
+    if err != nil {
+      log.Fatalln(err)
+    }
+    lifecycleHooks = append(lifecycleHooks, hook)
+  }
+
+  notificationTypes, err := aws.ParseNotificationTypes(*notificationTypesStr)
   if err != nil {
     log.Fatalln(err)
   }
-  log.Printf("will create an AMI %q from the instance %s", rc.GetAMIName(), rc.InstanceID)
-  log.Printf("will create a launch template %q", rc.GetLaunchTemplateName())
-  log.Printf("will create a target group %q in VPC %s", rc.GetTargetGroupName(), defaultVPCID)
-  log.Printf("will create a load balancer %q in subnets %s", rc.GetBalancerName(), strings.Join(subnetIDs, ", "))
-  log.Printf("will create an auto scaling group %q with %d %s spot instances", rc.GetGroupName(), rc.InstancesCount, instanceData.InstanceType)
-  if err := client.CreateAMI(); err != nil {
-    FatalError(err, client)
+
+  var terminationPolicies []string
+  if *terminationPoliciesStr != "" {
+    for _, policy := range strings.Split(*terminationPoliciesStr, ",") {
+      terminationPolicies = append(terminationPolicies, strings.TrimSpace(policy))
+    }
   }
-  if err := client.CreateLaunchTemplate(instanceData); err != nil {
-    FatalError(err, client)
+
+  var warmPoolConfig *aws.WarmPool
+  if *warmPool {
+    warmPoolConfig, err = aws.NewWarmPool(*warmPoolMinSize, *warmPoolMaxPrepared, *warmPoolState, *warmPoolReuse)
+    if err != nil {
+      log.Fatalln(err)
+    }
   }
-  if err := client.CreateTargetGroup(instanceData); err != nil {
-    FatalError(err, client)
+
+  return &aws.RunConfig{
+    InstanceID: *instanceID,
+    GroupName:  *groupName,
+    Env:        *env,
+    NamingTemplates: aws.NamingTemplates{
+      Group:          *groupNameTemplate,
+      LaunchTemplate: *launchTemplateNameTemplate,
+      TargetGroup:    *targetGroupNameTemplate,
+      Balancer:       *balancerNameTemplate,
+      AMI:            *amiNameTemplate,
+    },
+    HealthPath:             *healthPath,
+    DaemonPort:             int32(*daemonPort),
+    InstancesCount:         int32(*instancesCount),
+    LifecycleHooks:         lifecycleHooks,
+    WarmPool:               warmPoolConfig,
+    NotificationTopicARN:   *notificationTopic,
+    NotificationTypes:      notificationTypes,
+    NotifyURL:              *notifyURL,
+    HealthCheckGracePeriod: healthCheckGracePeriod,
+    TerminationPolicies:    terminationPolicies,
+    ScaleInProtection:      *scaleInProtection,
+    MaxInstanceLifetime:    maxInstanceLifetime,
+    DefaultCooldown:        defaultCooldown,
+    UpdateTimeout:          updateTimeout,
+    UpdateTick:             updateTick,
+    ExistingBalancer:       *existingBalancer,
+    LoadBalancerAttributes: &aws.LoadBalancerAttributes{
+      AccessLogsBucket:     *accessLogsBucket,
+      AccessLogsPrefix:     *accessLogsPrefix,
+      DeletionProtection:   *deletionProtection,
+      IdleTimeout:          idleTimeout,
+      HTTP2:                *http2,
+      DropInvalidHeaders:   *dropInvalidHeaders,
+      DesyncMitigationMode: *desyncMitigationMode,
+    },
+    ListenerPort:   int32(*listenerPort),
+    HostHeader:     *hostHeader,
+    PathPattern:    *pathPattern,
+    DNSName:        *dnsName,
+    HostedZoneID:   *hostedZoneID,
+    SmokeTest:      *smokeTest,
+    SmokeChecks:    smokeChecks,
+    SmokeSuccesses: *smokeSuccesses,
+    SmokeInterval:  smokeInterval,
+    SmokeTimeout:   smokeTimeout,
+    SmokeCleanup:   *smokeCleanup,
+    ReportPath:     *reportPath,
+    Emit:           *emit,
+    EmitFormat:     aws.TemplateFormat(*emitFormat),
+    RetryPolicy: aws.RetryPolicy{
+      MaxAttempts: *maxAttempts,
+      BaseDelay:   retryBaseDelay,
+      MaxDelay:    retryMaxDelay,
+    },
+  }
+}
+
+func main() {
+  if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+    runCommand(os.Args[1], os.Args[2:])
+    return
   }
-  if err := client.CreateLoadBalancer(subnetIDs); err != nil {
-    FatalError(err, client)
+  rc := initRunConfig(flag.CommandLine, os.Args[1:])
+  if rc.Emit != "" {
+    template, err := aws.NewBuilder().EmitTemplate(context.Background(), rc)
+    if err != nil {
+      log.Fatalln(err)
+    }
+    fmt.Print(template)
+    return
   }
-  if err := client.CreateAutoScalingGroup(subnetIDs); err != nil {
-    FatalError(err, client)
+  if _, err := aws.NewBuilder().Build(context.Background(), rc); err != nil {
+    log.Fatalln(err)
   }
-  client.ReportCreatedArtifacts()
 }